@@ -0,0 +1,88 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"bitcoin-prices/internal/pairs"
+)
+
+// Condition selects when an alert fires.
+type Condition string
+
+const (
+	// CrossAbove fires when the price moves from below the threshold to at or above it.
+	CrossAbove Condition = "cross_above"
+	// CrossBelow fires when the price moves from above the threshold to at or below it.
+	CrossBelow Condition = "cross_below"
+	// Change fires when the price moves by at least ChangePct percent (either
+	// direction) within WindowMinutes. It does not fire again within the window.
+	Change Condition = "change"
+)
+
+// maxWindowMinutes bounds the look-back window of Change alerts.
+const maxWindowMinutes = 24 * 60
+
+// Alert is a price alert delivered to a webhook.
+type Alert struct {
+	ID              string     `json:"id"`
+	Pair            string     `json:"pair"`
+	Condition       Condition  `json:"condition"`
+	Threshold       float64    `json:"threshold,omitempty"`
+	ChangePct       float64    `json:"change_pct,omitempty"`
+	WindowMinutes   int        `json:"window_minutes,omitempty"`
+	WebhookURL      string     `json:"webhook_url"`
+	Secret          string     `json:"secret,omitempty"` // HMAC key for payload signatures
+	CreatedAt       time.Time  `json:"created_at"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+}
+
+// Redacted returns a copy without the webhook secret, for listing.
+func (a Alert) Redacted() Alert {
+	a.Secret = ""
+	return a
+}
+
+// Validate normalizes the pair and checks the alert definition.
+func (a *Alert) Validate() error {
+	p, err := pairs.NormalizePair(a.Pair)
+	if err != nil {
+		return err
+	}
+	a.Pair = p
+	switch a.Condition {
+	case CrossAbove, CrossBelow:
+		if a.Threshold <= 0 {
+			return errors.New("threshold must be positive")
+		}
+	case Change:
+		if a.ChangePct <= 0 {
+			return errors.New("change_pct must be positive")
+		}
+		if a.WindowMinutes < 1 || a.WindowMinutes > maxWindowMinutes {
+			return fmt.Errorf("window_minutes must be between 1 and %d", maxWindowMinutes)
+		}
+	default:
+		return fmt.Errorf("unsupported condition: %q", a.Condition)
+	}
+	u, err := url.Parse(a.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook_url: %q", a.WebhookURL)
+	}
+	return nil
+}
+
+func (a Alert) window() time.Duration {
+	return time.Duration(a.WindowMinutes) * time.Minute
+}
+
+// randomHex returns n random bytes hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
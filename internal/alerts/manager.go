@@ -0,0 +1,313 @@
+package alerts
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"bitcoin-prices/internal/clock"
+)
+
+// maxObservations bounds the price history kept per pair for Change alerts.
+const maxObservations = 10000
+
+type observation struct {
+	at    time.Time
+	price float64
+}
+
+// updateQueueSize bounds the price updates waiting for evaluation.
+const updateQueueSize = 1024
+
+// priceUpdate is a price handed from Observe to the evaluation worker.
+type priceUpdate struct {
+	pair  string
+	price float64
+	at    time.Time
+}
+
+// Manager owns the alert definitions and evaluates them against price updates.
+type Manager struct {
+	store   *Store
+	deliver *Deliverer
+	log     *slog.Logger
+	clock   clock.Clock
+
+	mu      sync.Mutex               // serializes evaluation with alert changes
+	history map[string][]observation // per pair, ascending by time
+
+	updates   chan priceUpdate
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewManager evaluates alerts from store and sends events through d. clk
+// times creation and polling (nil: clock.Real). Call Close to stop it.
+func NewManager(store *Store, d *Deliverer, log *slog.Logger, clk clock.Clock) *Manager {
+	if clk == nil {
+		clk = clock.Real
+	}
+	m := &Manager{
+		store:   store,
+		deliver: d,
+		log:     log,
+		clock:   clk,
+		history: make(map[string][]observation),
+		updates: make(chan priceUpdate, updateQueueSize),
+		done:    make(chan struct{}),
+	}
+	m.wg.Add(1)
+	go m.evaluateUpdates()
+	return m
+}
+
+// List returns all alerts without their secrets.
+func (m *Manager) List() []Alert {
+	list := m.store.List()
+	for i := range list {
+		list[i] = list[i].Redacted()
+	}
+	return list
+}
+
+// Get returns an alert without its secret.
+func (m *Manager) Get(id string) (Alert, error) {
+	a, err := m.store.Get(id)
+	return a.Redacted(), err
+}
+
+// Create validates and stores a new alert. A secret is generated if none is
+// given; the returned alert is the only place it is shown.
+func (m *Manager) Create(a Alert) (Alert, error) {
+	if err := m.validate(&a); err != nil {
+		return Alert{}, err
+	}
+	a.ID = randomHex(8)
+	if a.Secret == "" {
+		a.Secret = randomHex(32)
+	}
+	a.CreatedAt = m.clock.Now().UTC()
+	a.LastTriggeredAt = nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.store.Put(a); err != nil {
+		return Alert{}, err
+	}
+	return a, nil
+}
+
+// Update replaces the definition of an existing alert, keeping its ID, creation
+// time and (unless a new one is given) its secret.
+func (m *Manager) Update(id string, a Alert) (Alert, error) {
+	if err := m.validate(&a); err != nil {
+		return Alert{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, err := m.store.Get(id)
+	if err != nil {
+		return Alert{}, err
+	}
+	a.ID, a.CreatedAt, a.LastTriggeredAt = old.ID, old.CreatedAt, nil
+	if a.Secret == "" {
+		a.Secret = old.Secret
+	}
+	if err := m.store.Put(a); err != nil {
+		return Alert{}, err
+	}
+	return a.Redacted(), nil
+}
+
+// validate checks the definition and that the webhook may be delivered to.
+func (m *Manager) validate(a *Alert) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return m.deliver.policy.checkURL(a.WebhookURL)
+}
+
+// Delete removes an alert.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store.Delete(id)
+}
+
+// Observe queues a price update for evaluation against the alerts. It never
+// blocks, so it can be used as a service quote listener: firing alerts is
+// left to a worker, as it saves the alerts file and may write the
+// dead-letter log. Updates arriving while the queue is full are dropped.
+func (m *Manager) Observe(pair string, price float64, at time.Time) {
+	if price <= 0 || math.IsNaN(price) {
+		return
+	}
+	select {
+	case <-m.done:
+	case m.updates <- priceUpdate{pair: pair, price: price, at: at}:
+	default:
+		m.log.Warn("alert evaluation falling behind, price update dropped", "pair", pair)
+	}
+}
+
+// Price is a pair's price and when it was fetched from Kraken.
+type Price struct {
+	Value float64
+	At    time.Time
+}
+
+// PriceFunc returns the current price of each of pairs, from a cache or
+// Kraken.
+type PriceFunc func(ctx context.Context, pairs []string) (map[string]Price, error)
+
+// Poll observes the prices of the alerted pairs every interval until Close,
+// so alerts are evaluated without client traffic, and on every replica when
+// the price cache is shared. Prices observed before, by Observe or an
+// earlier poll, are ignored.
+func (m *Manager) Poll(prices PriceFunc, interval time.Duration) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		t := m.clock.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-t.C():
+				m.poll(prices, interval)
+			}
+		}
+	}()
+}
+
+func (m *Manager) poll(prices PriceFunc, timeout time.Duration) {
+	seen := make(map[string]bool)
+	var ps []string
+	for _, a := range m.store.List() {
+		if !seen[a.Pair] {
+			seen[a.Pair] = true
+			ps = append(ps, a.Pair)
+		}
+	}
+	if len(ps) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	got, err := prices(ctx, ps)
+	if err != nil {
+		m.log.Warn("alert price poll failed", "err", err)
+		return
+	}
+	for pair, p := range got {
+		m.Observe(pair, p.Value, p.At)
+	}
+}
+
+func (m *Manager) evaluateUpdates() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.done:
+			return
+		case u := <-m.updates:
+			m.observe(u.pair, u.price, u.at)
+		}
+	}
+}
+
+// observe records a price update and fires matching alerts.
+func (m *Manager) observe(pair string, price float64, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hist := m.history[pair]
+	var prev *observation
+	if n := len(hist); n > 0 {
+		if !at.After(hist[n-1].at) {
+			return // out-of-order or duplicate update
+		}
+		prev = &hist[n-1]
+	}
+
+	for _, a := range m.store.List() {
+		if a.Pair != pair {
+			continue
+		}
+		ref, fire := m.evaluate(a, hist, prev, price, at)
+		if !fire {
+			continue
+		}
+		t := at.UTC()
+		a.LastTriggeredAt = &t
+		if err := m.store.Put(a); err != nil {
+			m.log.Error("alert state save failed", "alert_id", a.ID, "err", err)
+		}
+		m.deliver.Enqueue(a.WebhookURL, a.Secret, Event{
+			AlertID:     a.ID,
+			Pair:        a.Pair,
+			Condition:   a.Condition,
+			Threshold:   a.Threshold,
+			ChangePct:   a.ChangePct,
+			Price:       price,
+			Reference:   ref,
+			TriggeredAt: t,
+		})
+	}
+
+	hist = append(hist, observation{at: at, price: price})
+	cutoff := at.Add(-maxWindowMinutes * time.Minute)
+	i := 0
+	for i < len(hist) && hist[i].at.Before(cutoff) {
+		i++
+	}
+	if len(hist)-i > maxObservations {
+		i = len(hist) - maxObservations
+	}
+	m.history[pair] = hist[i:]
+}
+
+// evaluate reports whether a fires for price at time at, and the reference
+// price it was compared against.
+func (m *Manager) evaluate(a Alert, hist []observation, prev *observation, price float64, at time.Time) (float64, bool) {
+	switch a.Condition {
+	case CrossAbove:
+		return refPrice(prev), prev != nil && prev.price < a.Threshold && price >= a.Threshold
+	case CrossBelow:
+		return refPrice(prev), prev != nil && prev.price > a.Threshold && price <= a.Threshold
+	case Change:
+		if a.LastTriggeredAt != nil && at.Sub(*a.LastTriggeredAt) < a.window() {
+			return 0, false
+		}
+		start := at.Add(-a.window())
+		for _, o := range hist {
+			if o.at.Before(start) {
+				continue
+			}
+			// oldest observation inside the window
+			pct := (price - o.price) / o.price * 100
+			return o.price, math.Abs(pct) >= a.ChangePct
+		}
+	}
+	return 0, false
+}
+
+func refPrice(o *observation) float64 {
+	if o == nil {
+		return 0
+	}
+	return o.price
+}
+
+// Close stops polling and evaluating price updates, dropping those still
+// queued, and stops webhook delivery.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		m.deliver.Close()
+	})
+}
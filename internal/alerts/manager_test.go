@@ -0,0 +1,184 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock"
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+// receiver is an httptest webhook endpoint collecting verified events.
+func receiver(t *testing.T, secret string) (*httptest.Server, chan Event) {
+	t.Helper()
+	events := make(chan Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("bad signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var ev Event
+		_ = json.Unmarshal(body, &ev)
+		events <- ev
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events
+}
+
+func newTestManager(t *testing.T, clk clock.Clock) *Manager {
+	t.Helper()
+	store, _ := OpenStore("")
+	d := NewDeliverer(DelivererConfig{Attempts: 1, AllowedNetworks: loopback}, discardLogger())
+	m := NewManager(store, d, discardLogger(), clk)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func expectEvent(t *testing.T, events chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected webhook event")
+	}
+	return Event{}
+}
+
+func expectNoEvent(t *testing.T, events chan Event) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event: %+v", ev)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestManager_CrossAbove(t *testing.T) {
+	srv, events := receiver(t, "k")
+	m := newTestManager(t, nil)
+	a, err := m.Create(Alert{Pair: "btc/usd", Condition: CrossAbove, Threshold: 60000, WebhookURL: srv.URL, Secret: "k"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	t0 := time.Now()
+	m.Observe("BTC/USD", 60500, t0) // first observation: no previous price, no crossing
+	m.Observe("BTC/USD", 59000, t0.Add(time.Second))
+	expectNoEvent(t, events)
+
+	m.Observe("BTC/USD", 60000, t0.Add(2*time.Second))
+	ev := expectEvent(t, events)
+	if ev.AlertID != a.ID || ev.Price != 60000 || ev.Reference != 59000 {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	m.Observe("BTC/USD", 61000, t0.Add(3*time.Second)) // still above: no new crossing
+	expectNoEvent(t, events)
+	if got, _ := m.Get(a.ID); got.LastTriggeredAt == nil || got.Secret != "" {
+		t.Fatalf("expected trigger time recorded and secret redacted, got %+v", got)
+	}
+}
+
+func TestManager_ChangeWithinWindow(t *testing.T) {
+	srv, events := receiver(t, "k")
+	m := newTestManager(t, nil)
+	if _, err := m.Create(Alert{Pair: "BTC/EUR", Condition: Change, ChangePct: 5, WindowMinutes: 10, WebhookURL: srv.URL, Secret: "k"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	t0 := time.Now()
+	m.Observe("BTC/EUR", 100, t0)
+	m.Observe("BTC/EUR", 104, t0.Add(time.Minute))
+	expectNoEvent(t, events)
+
+	m.Observe("BTC/EUR", 94, t0.Add(2*time.Minute)) // -6% vs window start
+	ev := expectEvent(t, events)
+	if ev.Reference != 100 || ev.Price != 94 {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	m.Observe("BTC/EUR", 80, t0.Add(3*time.Minute)) // within cooldown window
+	expectNoEvent(t, events)
+
+	// 11 minutes later the window only contains the 94 and 80 observations
+	m.Observe("BTC/EUR", 100, t0.Add(13*time.Minute))
+	if ev := expectEvent(t, events); ev.Reference != 80 {
+		t.Fatalf("expected reference from window start, got %+v", ev)
+	}
+}
+
+func TestManager_ObserveDoesNotBlock(t *testing.T) {
+	m := newTestManager(t, nil)
+	// evaluation stalls, as it would on a slow alerts file
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		t0 := time.Now()
+		for i := 0; i < 2*updateQueueSize; i++ {
+			m.Observe("BTC/USD", 60000, t0.Add(time.Duration(i)*time.Second))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Observe not to wait for evaluation")
+	}
+}
+
+func TestManager_PollFiresWithoutTraffic(t *testing.T) {
+	srv, events := receiver(t, "k")
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	m := newTestManager(t, clk)
+	a, err := m.Create(Alert{Pair: "BTC/USD", Condition: CrossAbove, Threshold: 60000, WebhookURL: srv.URL, Secret: "k"})
+	if err != nil || !a.CreatedAt.Equal(clk.Now()) {
+		t.Fatalf("create: %+v err=%v", a, err)
+	}
+	t0 := clk.Now()
+	var polls int
+	asked := make(chan []string, 10)
+	m.Poll(func(ctx context.Context, pairs []string) (map[string]Price, error) {
+		// runs on the poller goroutine only
+		polls++
+		select {
+		case asked <- pairs:
+		default:
+		}
+		price := 59000.0
+		if polls > 1 {
+			price = 61000
+		}
+		return map[string]Price{"BTC/USD": {Value: price, At: t0.Add(time.Duration(polls) * time.Second)}}, nil
+	}, time.Minute)
+
+	clk.BlockUntil(1) // the poller's ticker
+	clk.Advance(time.Minute)
+	if ps := <-asked; len(ps) != 1 || ps[0] != "BTC/USD" {
+		t.Fatalf("expected the alerted pair polled, got %v", ps)
+	}
+	expectNoEvent(t, events)
+	clk.Advance(time.Minute)
+	if ev := expectEvent(t, events); ev.Price != 61000 || ev.Reference != 59000 {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestAlert_Validate(t *testing.T) {
+	bad := []Alert{
+		{Pair: "ETH/USD", Condition: CrossAbove, Threshold: 1, WebhookURL: "http://x"},
+		{Pair: "BTC/USD", Condition: "sideways", WebhookURL: "http://x"},
+		{Pair: "BTC/USD", Condition: CrossBelow, WebhookURL: "http://x"},
+		{Pair: "BTC/USD", Condition: Change, ChangePct: 1, WindowMinutes: 0, WebhookURL: "http://x"},
+		{Pair: "BTC/USD", Condition: CrossAbove, Threshold: 1, WebhookURL: "ftp://x"},
+	}
+	for _, a := range bad {
+		if err := a.Validate(); err == nil {
+			t.Fatalf("expected validation error for %+v", a)
+		}
+	}
+}
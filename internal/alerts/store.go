@@ -0,0 +1,153 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned for unknown alert IDs.
+	ErrNotFound = errors.New("alert not found")
+	// ErrPersist wraps failures to write the alerts file.
+	ErrPersist = errors.New("persist alerts")
+)
+
+// Store keeps alerts in memory and, if a path is set, persists them to a JSON
+// file after every change so they survive restarts. The file holds the
+// webhook secrets, so it is written readable by its owner only (0600).
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	alerts map[string]Alert
+}
+
+// OpenStore loads alerts from path. A missing file yields an empty store;
+// an empty path keeps alerts in memory only.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, alerts: make(map[string]Alert)}
+	if path == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Alert
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, a := range list {
+		s.alerts[a.ID] = a
+	}
+	return s, nil
+}
+
+// List returns all alerts ordered by creation time.
+func (s *Store) List() []Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listLocked()
+}
+
+func (s *Store) listLocked() []Alert {
+	out := make([]Alert, 0, len(s.alerts))
+	for _, a := range s.alerts {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// Get returns the alert with id.
+func (s *Store) Get(id string) (Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.alerts[id]
+	if !ok {
+		return Alert{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// Put inserts or replaces an alert and persists the store.
+func (s *Store) Put(a Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.alerts[a.ID]
+	s.alerts[a.ID] = a
+	if err := s.saveLocked(); err != nil {
+		if existed {
+			s.alerts[a.ID] = prev
+		} else {
+			delete(s.alerts, a.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes an alert and persists the store.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.alerts[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.alerts, id)
+	if err := s.saveLocked(); err != nil {
+		s.alerts[id] = prev
+		return err
+	}
+	return nil
+}
+
+// saveLocked writes the store atomically (temp file + rename).
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := s.writeFile(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPersist, err)
+	}
+	return nil
+}
+
+func (s *Store) writeFile() error {
+	b, err := json.MarshalIndent(s.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// CreateTemp creates the file with mode 0600
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".alerts-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	// flush to disk before the rename, so a crash cannot leave an empty file
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
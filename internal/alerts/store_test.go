@@ -0,0 +1,66 @@
+package alerts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	a := Alert{ID: "a1", Pair: "BTC/USD", Condition: CrossAbove, Threshold: 1, WebhookURL: "http://x", Secret: "k", CreatedAt: time.Now()}
+	if err := s.Put(a); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s.Put(Alert{ID: "a2", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s.Delete("a2"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// the file holds webhook secrets
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("expected an owner-only file, got %v err=%v", fi.Mode(), err)
+	}
+
+	s2, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := s2.Get("a1")
+	if err != nil || got.Secret != "k" || got.Threshold != 1 {
+		t.Fatalf("expected persisted alert, got %+v err=%v", got, err)
+	}
+	if _, err := s2.Get("a2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected deleted alert to stay deleted, got %v", err)
+	}
+}
+
+func TestStore_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Fatalf("expected error for corrupt file")
+	}
+}
+
+func TestStore_WriteFailureRollsBack(t *testing.T) {
+	s, err := OpenStore(filepath.Join(t.TempDir(), "missing-dir", "alerts.json"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := s.Put(Alert{ID: "a1"}); !errors.Is(err, ErrPersist) {
+		t.Fatalf("expected ErrPersist, got %v", err)
+	}
+	if len(s.List()) != 0 {
+		t.Fatalf("expected failed put to be rolled back")
+	}
+}
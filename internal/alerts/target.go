@@ -0,0 +1,95 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget is returned for webhooks on addresses that are not
+// public, unless their network is allowed.
+var ErrForbiddenTarget = errors.New("webhook target is not a public address")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), not routable publicly.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// ParseNetworks parses CIDRs ("10.0.0.0/8") and single addresses, e.g. for
+// DelivererConfig.AllowedNetworks.
+func ParseNetworks(cidrs []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range cidrs {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid network %q (want a CIDR or an IP)", s)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		out = append(out, prefix.Masked())
+	}
+	return out, nil
+}
+
+// targetPolicy keeps webhooks away from the server's own network: loopback,
+// private (RFC 1918, fc00::/7), link-local (cloud metadata endpoints),
+// shared, multicast and unspecified addresses are refused unless in allowed.
+type targetPolicy struct {
+	allowed []netip.Prefix
+}
+
+func (p targetPolicy) permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, n := range p.allowed {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() &&
+		!addr.IsUnspecified() && !sharedAddressSpace.Contains(addr)
+}
+
+// checkURL rejects webhook URLs whose host is a forbidden address literal or
+// localhost. Host names are checked when connecting, see client.
+func (p targetPolicy) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook_url: %q", raw)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !p.permits(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, u.Hostname())
+	}
+	return nil
+}
+
+// client returns an HTTP client that refuses to connect to forbidden
+// addresses, checked after DNS resolution so names resolving to internal
+// addresses and redirects to them are caught too. It does not use a proxy,
+// which would hide the target address.
+func (p targetPolicy) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !p.permits(ap.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, address)
+			}
+			return nil
+		},
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
@@ -0,0 +1,258 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"bitcoin-prices/internal/clock"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, as "sha256=<hex>".
+const SignatureHeader = "X-Signature-256"
+
+// Event is the webhook payload sent when an alert fires.
+type Event struct {
+	AlertID     string    `json:"alert_id"`
+	Pair        string    `json:"pair"`
+	Condition   Condition `json:"condition"`
+	Threshold   float64   `json:"threshold,omitempty"`
+	ChangePct   float64   `json:"change_pct,omitempty"`
+	Price       float64   `json:"price"`
+	Reference   float64   `json:"reference_price"` // previous price (cross) or window start price (change)
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// Sign returns the signature header value for body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time.
+func Verify(secret string, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(sig))
+}
+
+// DelivererConfig configures webhook delivery.
+type DelivererConfig struct {
+	Client         *http.Client  // default: 5s timeout, refusing forbidden targets (used as is if set)
+	Attempts       int           // total attempts per event (default 4)
+	Backoff        time.Duration // delay before the first retry, doubled each time (default 500ms)
+	Workers        int           // concurrent deliveries (default 2)
+	QueueSize      int           // pending deliveries before events are dead-lettered (default 100)
+	DeadLetterPath string        // JSON lines file for undeliverable events; empty logs only
+	// DeadLetterMaxBytes caps the dead-letter file (default 10 MiB). When a
+	// record would exceed it, the file is moved to DeadLetterPath+".1",
+	// replacing the previous one, and a new file is started.
+	DeadLetterMaxBytes int64
+	// AllowedNetworks may receive webhooks even though they are not public,
+	// e.g. a receiver on the internal network.
+	AllowedNetworks []netip.Prefix
+	Clock           clock.Clock // times backoff and dead letters (default clock.Real)
+}
+
+type delivery struct {
+	url    string
+	secret string
+	event  Event
+}
+
+// deadLetter is a record in the dead-letter log.
+type deadLetter struct {
+	AlertID  string          `json:"alert_id"`
+	URL      string          `json:"url"`
+	Payload  json.RawMessage `json:"payload"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// Deliverer posts signed events to webhooks asynchronously, retrying with
+// exponential backoff on network errors, 429 and 5xx. Events that cannot be
+// delivered are appended to the dead-letter log.
+type Deliverer struct {
+	cfg    DelivererConfig
+	log    *slog.Logger
+	policy targetPolicy
+
+	mu     sync.Mutex // guards closed and the dead-letter file
+	closed bool
+	queue  chan delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDeliverer starts the delivery workers. Call Close to stop them.
+func NewDeliverer(cfg DelivererConfig, log *slog.Logger) *Deliverer {
+	policy := targetPolicy{allowed: cfg.AllowedNetworks}
+	if cfg.Client == nil {
+		cfg.Client = policy.client(5 * time.Second)
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 4
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 500 * time.Millisecond
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+	if cfg.DeadLetterMaxBytes <= 0 {
+		cfg.DeadLetterMaxBytes = 10 << 20
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Deliverer{cfg: cfg, log: log, policy: policy, queue: make(chan delivery, cfg.QueueSize), ctx: ctx, cancel: cancel}
+	d.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Enqueue schedules an event for delivery without blocking.
+func (d *Deliverer) Enqueue(url, secret string, ev Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.deadLetterLocked(delivery{url, secret, ev}, 0, fmt.Errorf("deliverer closed"))
+		return
+	}
+	select {
+	case d.queue <- delivery{url, secret, ev}:
+	default:
+		d.deadLetterLocked(delivery{url, secret, ev}, 0, fmt.Errorf("delivery queue full"))
+	}
+}
+
+// Close stops accepting events, aborts pending retries and waits for workers.
+// Events still queued are dead-lettered.
+func (d *Deliverer) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *Deliverer) worker() {
+	defer d.wg.Done()
+	for dl := range d.queue {
+		d.deliver(dl)
+	}
+}
+
+func (d *Deliverer) deliver(dl delivery) {
+	body, err := json.Marshal(dl.event)
+	if err != nil {
+		d.deadLetter(dl, 0, err)
+		return
+	}
+	var lastErr error
+	for attempt := 0; attempt < d.cfg.Attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-d.ctx.Done():
+				d.deadLetter(dl, attempt, fmt.Errorf("aborted: %w", lastErr))
+				return
+			case <-d.cfg.Clock.After(d.cfg.Backoff << (attempt - 1)):
+			}
+		}
+		retry, err := d.post(dl, body)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retry {
+			d.deadLetter(dl, attempt+1, err)
+			return
+		}
+	}
+	d.deadLetter(dl, d.cfg.Attempts, lastErr)
+}
+
+// post performs a single attempt. It reports whether the error is retryable.
+func (d *Deliverer) post(dl delivery, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, dl.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(dl.secret, body))
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrForbiddenTarget), err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook http %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook http %d", resp.StatusCode)
+	}
+}
+
+func (d *Deliverer) deadLetter(dl delivery, attempts int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadLetterLocked(dl, attempts, err)
+}
+
+func (d *Deliverer) deadLetterLocked(dl delivery, attempts int, err error) {
+	d.log.Error("alert webhook undeliverable", "alert_id", dl.event.AlertID, "url", dl.url, "attempts", attempts, "err", err)
+	if d.cfg.DeadLetterPath == "" {
+		return
+	}
+	payload, _ := json.Marshal(dl.event)
+	rec, _ := json.Marshal(deadLetter{
+		AlertID:  dl.event.AlertID,
+		URL:      dl.url,
+		Payload:  payload,
+		Error:    err.Error(),
+		Attempts: attempts,
+		FailedAt: d.cfg.Clock.Now().UTC(),
+	})
+	rec = append(rec, '\n')
+	if fi, err := os.Stat(d.cfg.DeadLetterPath); err == nil && fi.Size() > 0 && fi.Size()+int64(len(rec)) > d.cfg.DeadLetterMaxBytes {
+		if err := os.Rename(d.cfg.DeadLetterPath, d.cfg.DeadLetterPath+".1"); err != nil {
+			d.log.Error("dead-letter rotation failed", "path", d.cfg.DeadLetterPath, "err", err)
+		}
+	}
+	f, ferr := os.OpenFile(d.cfg.DeadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if ferr != nil {
+		d.log.Error("dead-letter write failed", "path", d.cfg.DeadLetterPath, "err", ferr)
+		return
+	}
+	defer f.Close()
+	if _, ferr := f.Write(rec); ferr != nil {
+		d.log.Error("dead-letter write failed", "path", d.cfg.DeadLetterPath, "err", ferr)
+	}
+}
@@ -0,0 +1,219 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+func discardLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// undeliverable returns a logger and a channel receiving the alert ID of
+// each event the Deliverer gives up on, once it is dead-lettered.
+func undeliverable() (*slog.Logger, chan string) {
+	ch := make(chan string, 10)
+	return slog.New(&notifyHandler{msg: "alert webhook undeliverable", ch: ch}), ch
+}
+
+type notifyHandler struct {
+	msg string
+	ch  chan string
+}
+
+func (h *notifyHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *notifyHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *notifyHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *notifyHandler) Handle(_ context.Context, r slog.Record) error {
+	if r.Message == h.msg {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "alert_id" {
+				h.ch <- a.Value.String()
+			}
+			return true
+		})
+	}
+	return nil
+}
+
+func expectUndeliverable(t *testing.T, ch chan string, id string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != id {
+			t.Fatalf("expected %s undeliverable, got %s", id, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected %s undeliverable", id)
+	}
+}
+
+// loopback lets deliveries reach httptest servers.
+var loopback, _ = ParseNetworks([]string{"127.0.0.0/8", "::1"})
+
+func TestDeliverer_SignsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("s3cret", body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Errorf("bad payload: %v", err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	d := NewDeliverer(DelivererConfig{Attempts: 3, Backoff: time.Minute, AllowedNetworks: loopback, Clock: clk}, discardLogger())
+	defer d.Close()
+	d.Enqueue(srv.URL, "s3cret", Event{AlertID: "a1", Pair: "BTC/USD", Price: 60000})
+	// the backoff doubles: 1m, then 2m
+	for _, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		clk.BlockUntil(1)
+		clk.Advance(wait)
+	}
+
+	select {
+	case ev := <-got:
+		if ev.AlertID != "a1" || ev.Price != 60000 {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("event not delivered, calls=%d", calls.Load())
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestDeliverer_DeadLetter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	dlq := filepath.Join(t.TempDir(), "dead.jsonl")
+	log, failed := undeliverable()
+	d := NewDeliverer(DelivererConfig{Attempts: 2, Backoff: time.Millisecond, DeadLetterPath: dlq, AllowedNetworks: loopback}, log)
+	d.Enqueue(srv.URL, "k", Event{AlertID: "a1"})
+	d.Enqueue(srv.URL+"/gone", "k", Event{AlertID: "a2"})
+	// the two deliveries run concurrently, so either may give up first
+	gaveUp := map[string]bool{}
+	for len(gaveUp) < 2 {
+		select {
+		case id := <-failed:
+			gaveUp[id] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected a1 and a2 undeliverable, got %v", gaveUp)
+		}
+	}
+	if !gaveUp["a1"] || !gaveUp["a2"] {
+		t.Fatalf("expected a1 and a2 undeliverable, got %v", gaveUp)
+	}
+	d.Close() // waits for the dead letters to be written
+
+	b, _ := os.ReadFile(dlq)
+	if strings.Count(string(b), "\n") != 2 || !strings.Contains(string(b), `"alert_id":"a1"`) || !strings.Contains(string(b), "webhook http 500") {
+		t.Fatalf("unexpected dead letters: %q", b)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("expected 2 attempts per event, got %d calls", n)
+	}
+}
+
+func TestDeliverer_RotatesDeadLetters(t *testing.T) {
+	dlq := filepath.Join(t.TempDir(), "dead.jsonl")
+	log, failed := undeliverable()
+	// every record is larger than the cap, so each one starts a new file
+	d := NewDeliverer(DelivererConfig{DeadLetterPath: dlq, DeadLetterMaxBytes: 1}, log)
+	for _, id := range []string{"a1", "a2", "a3"} {
+		d.Enqueue("http://127.0.0.1/hook", "k", Event{AlertID: id})
+		expectUndeliverable(t, failed, id)
+	}
+	d.Close()
+
+	cur, _ := os.ReadFile(dlq)
+	prev, _ := os.ReadFile(dlq + ".1")
+	if strings.Count(string(cur), "\n") != 1 || !strings.Contains(string(cur), `"alert_id":"a3"`) ||
+		strings.Count(string(prev), "\n") != 1 || !strings.Contains(string(prev), `"alert_id":"a2"`) {
+		t.Fatalf("expected a3 in the file and a2 rotated out, got %q and %q", cur, prev)
+	}
+}
+
+func TestDeliverer_NoRetryOn4xx(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	log, failed := undeliverable()
+	d := NewDeliverer(DelivererConfig{Attempts: 5, Backoff: time.Millisecond, AllowedNetworks: loopback}, log)
+	d.Enqueue(srv.URL, "k", Event{AlertID: "a1"})
+	expectUndeliverable(t, failed, "a1")
+	d.Close()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single attempt for 400, got %d", n)
+	}
+}
+
+func TestDeliverer_RefusesInternalTargets(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	dlq := filepath.Join(t.TempDir(), "dead.jsonl")
+	log, failed := undeliverable()
+	d := NewDeliverer(DelivererConfig{Attempts: 3, Backoff: time.Millisecond, DeadLetterPath: dlq}, log)
+	// a name resolving to loopback is caught when connecting
+	d.Enqueue(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), "k", Event{AlertID: "a1"})
+	expectUndeliverable(t, failed, "a1")
+	d.Close() // waits for the dead letter to be written
+	b, _ := os.ReadFile(dlq)
+	if calls.Load() != 0 || !strings.Contains(string(b), "not a public address") || !strings.Contains(string(b), `"attempts":1`) {
+		t.Fatalf("expected the delivery refused without retries, calls=%d dead letters %s", calls.Load(), b)
+	}
+}
+
+func TestTargetPolicy_CheckURL(t *testing.T) {
+	allowed, _ := ParseNetworks([]string{"10.1.0.0/16"})
+	p := targetPolicy{allowed: allowed}
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://[::1]/hook", "http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook", "http://192.168.1.1/hook", "http://[fd00::1]/hook", "http://0.0.0.0/hook", "http://[::ffff:127.0.0.1]/hook",
+	} {
+		if err := p.checkURL(u); !errors.Is(err, ErrForbiddenTarget) {
+			t.Fatalf("expected %s refused, got %v", u, err)
+		}
+	}
+	for _, u := range []string{"https://example.com/hook", "http://203.0.113.7/hook", "http://10.1.2.3/hook"} {
+		if err := p.checkURL(u); err != nil {
+			t.Fatalf("expected %s accepted, got %v", u, err)
+		}
+	}
+}
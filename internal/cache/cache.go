@@ -1,67 +1,338 @@
 package cache
 
 import (
+	"container/list"
+	"errors"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"bitcoin-prices/internal/clock"
 )
 
+// Cache is a key-value cache whose entries expire after a TTL.
+// TTLCache keeps entries in process memory; RedisCache shares them between
+// processes.
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	// Lookup is Get that also reports whether key is negatively cached.
+	Lookup(key K) (val V, found, negative bool)
+	Set(key K, val V)
+	SetWithTTL(key K, val V, ttl time.Duration)
+	GetOrSet(key K, supplier Supplier[V]) (V, error)
+}
+
+// Supplier computes a value for GetOrSet along with the TTL to cache it for
+// (0 means the cache default). Returning an error that wraps ErrNotFound caches
+// the absence of the key for the returned TTL (negative caching); other errors
+// are not cached.
+type Supplier[V any] func() (V, time.Duration, error)
+
+// NoExpiration as a TTL keeps an entry until it is evicted for capacity. It
+// lies outside the range of computed TTLs, which must be kept positive:
+// other non-positive TTLs mean the cache default.
+const NoExpiration time.Duration = math.MinInt64
+
+// ErrNotFound is returned by GetOrSet while a key is negatively cached.
+var ErrNotFound = errors.New("cache: not found")
+
 type entry[T any] struct {
 	val       T
-	expiresAt time.Time
+	expiresAt time.Time // zero means no expiration
+	negative  bool
+}
+
+func (e entry[T]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+type item[K comparable, V any] struct {
+	key K
+	entry[V]
+}
+
+// EvictReason tells an eviction callback why an entry was removed.
+type EvictReason int
+
+const (
+	// Expired entries outlived the TTL (found on read or by the janitor).
+	Expired EvictReason = iota
+	// Capacity entries were the least recently used when the cache was full.
+	Capacity
+)
+
+func (r EvictReason) String() string {
+	if r == Capacity {
+		return "capacity"
+	}
+	return "expired"
+}
+
+// Stats are cumulative counters since the cache was created.
+type Stats struct {
+	Hits         uint64
+	Misses       uint64
+	NegativeHits uint64 // reads of negatively cached keys, also counted as misses
+	Expirations  uint64
+	Evictions    uint64 // capacity evictions
+}
+
+// Option configures a TTLCache. Type parameters must be given explicitly,
+// e.g. cache.WithMaxEntries[string, int](1000).
+type Option[K comparable, V any] func(*TTLCache[K, V])
+
+// WithMaxEntries bounds the cache to n entries, evicting the least recently
+// used entry on overflow. n <= 0 means unbounded (the default).
+func WithMaxEntries[K comparable, V any](n int) Option[K, V] {
+	return func(c *TTLCache[K, V]) { c.maxEntries = n }
+}
+
+// WithJanitor sweeps expired entries every interval in a background goroutine,
+// stopped by Close. Without it, expired entries are only removed when read.
+func WithJanitor[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(c *TTLCache[K, V]) { c.janitorInterval = interval }
+}
+
+// WithEvictCallback calls fn for every expired or evicted entry, except
+// negative ones. It runs outside the cache lock, so it may use the cache.
+func WithEvictCallback[K comparable, V any](fn func(key K, val V, reason EvictReason)) Option[K, V] {
+	return func(c *TTLCache[K, V]) { c.onEvict = fn }
+}
+
+// WithClock sets the clock used for expiry and the janitor (default clock.Real).
+func WithClock[K comparable, V any](clk clock.Clock) Option[K, V] {
+	return func(c *TTLCache[K, V]) { c.clock = clk }
 }
 
 // TTLCache Simple in-memory TTL cache, concurrency-safe.
 // Zero-value is not ready; use New.
 type TTLCache[K comparable, V any] struct {
-	mu   sync.RWMutex
-	data map[K]entry[V]
-	ttl  time.Duration
+	mu    sync.Mutex
+	data  map[K]*list.Element // of *item[K, V]
+	order *list.List          // most recently used first
+	ttl   atomic.Int64        // default TTL as a time.Duration
+	stats Stats
+	clock clock.Clock
+
+	maxEntries      int
+	janitorInterval time.Duration
+	onEvict         func(K, V, EvictReason)
+	stop            chan struct{}
+	closeOnce       sync.Once
 }
 
-func New[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+func New[K comparable, V any](ttl time.Duration, opts ...Option[K, V]) *TTLCache[K, V] {
 	if ttl <= 0 {
 		ttl = 10 * time.Second
 	}
-	return &TTLCache[K, V]{
-		data: make(map[K]entry[V]),
-		ttl:  ttl,
+	c := &TTLCache[K, V]{
+		data:  make(map[K]*list.Element),
+		order: list.New(),
+		clock: clock.Real,
+		stop:  make(chan struct{}),
 	}
+	c.ttl.Store(int64(ttl))
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.janitorInterval > 0 {
+		go c.janitor()
+	}
+	return c
 }
 
 func (c *TTLCache[K, V]) Get(key K) (V, bool) {
-	c.mu.RLock()
-	e, ok := c.data[key]
-	c.mu.RUnlock()
-	if !ok || time.Now().After(e.expiresAt) {
-		var zero V
-		if ok {
-			c.mu.Lock()
-			delete(c.data, key)
-			c.mu.Unlock()
-		}
+	var zero V
+	c.mu.Lock()
+	el, ok := c.data[key]
+	if !ok {
+		c.stats.Misses++
+		c.mu.Unlock()
+		return zero, false
+	}
+	it := el.Value.(*item[K, V])
+	if it.expired(c.clock.Now()) {
+		c.removeLocked(el)
+		c.stats.Misses++
+		c.stats.Expirations++
+		c.mu.Unlock()
+		c.evicted(it, Expired)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	if it.negative {
+		c.stats.Misses++
+		c.stats.NegativeHits++
+		c.mu.Unlock()
 		return zero, false
 	}
-	return e.val, true
+	c.stats.Hits++
+	v := it.val
+	c.mu.Unlock()
+	return v, true
+}
+
+// Lookup is Get that also reports whether key is negatively cached, in which
+// case found is false and negative true.
+func (c *TTLCache[K, V]) Lookup(key K) (val V, found, negative bool) {
+	if v, ok := c.Get(key); ok {
+		return v, true, false
+	}
+	return val, false, c.Negative(key)
+}
+
+// Negative reports whether key is negatively cached (see SetNegative).
+func (c *TTLCache[K, V]) Negative(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.data[key]
+	if !ok {
+		return false
+	}
+	it := el.Value.(*item[K, V])
+	return it.negative && !it.expired(c.clock.Now())
 }
 
 func (c *TTLCache[K, V]) Set(key K, val V) {
-	e := entry[V]{val: val, expiresAt: time.Now().Add(c.ttl)}
+	c.SetWithTTL(key, val, 0)
+}
+
+// SetWithTTL stores val for ttl instead of the cache default. A ttl of 0 (or
+// any negative value other than NoExpiration) uses the default.
+func (c *TTLCache[K, V]) SetWithTTL(key K, val V, ttl time.Duration) {
+	c.put(key, entry[V]{val: val, expiresAt: c.expiry(ttl)})
+}
+
+// SetNegative records that key does not exist, so Get misses and GetOrSet
+// returns ErrNotFound without calling its supplier until ttl passes.
+func (c *TTLCache[K, V]) SetNegative(key K, ttl time.Duration) {
+	c.put(key, entry[V]{expiresAt: c.expiry(ttl), negative: true})
+}
+
+func (c *TTLCache[K, V]) expiry(ttl time.Duration) time.Time {
+	switch {
+	case ttl == NoExpiration:
+		return time.Time{}
+	case ttl <= 0:
+		ttl = c.TTL()
+	}
+	return c.clock.Now().Add(ttl)
+}
+
+// TTL returns the default TTL.
+func (c *TTLCache[K, V]) TTL() time.Duration { return time.Duration(c.ttl.Load()) }
+
+// SetDefaultTTL changes the default TTL for entries stored from now on;
+// existing entries keep their expiry. Non-positive values are ignored.
+func (c *TTLCache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	if ttl > 0 {
+		c.ttl.Store(int64(ttl))
+	}
+}
+
+func (c *TTLCache[K, V]) put(key K, e entry[V]) {
+	var victim *item[K, V]
 	c.mu.Lock()
-	c.data[key] = e
+	if el, ok := c.data[key]; ok {
+		el.Value.(*item[K, V]).entry = e
+		c.order.MoveToFront(el)
+	} else {
+		c.data[key] = c.order.PushFront(&item[K, V]{key: key, entry: e})
+		if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+			last := c.order.Back()
+			c.removeLocked(last)
+			c.stats.Evictions++
+			victim = last.Value.(*item[K, V])
+		}
+	}
 	c.mu.Unlock()
+	if victim != nil {
+		c.evicted(victim, Capacity)
+	}
 }
 
 // GetOrSet returns existing value if fresh, otherwise determines and sets using supplier.
-func (c *TTLCache[K, V]) GetOrSet(key K, supplier func() (V, error)) (V, error) {
-	if v, ok := c.Get(key); ok {
+// While key is negatively cached it returns ErrNotFound.
+func (c *TTLCache[K, V]) GetOrSet(key K, supplier Supplier[V]) (V, error) {
+	var zero V
+	if v, found, negative := c.Lookup(key); found {
 		return v, nil
+	} else if negative {
+		return zero, ErrNotFound
 	}
 	// compute outside lock
-	v, err := supplier()
+	v, ttl, err := supplier()
+	if errors.Is(err, ErrNotFound) {
+		c.SetNegative(key, ttl)
+		return zero, err
+	}
 	if err != nil {
-		var zero V
 		return zero, err
 	}
-	c.Set(key, v)
+	c.SetWithTTL(key, v, ttl)
 	return v, nil
 }
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns a copy of the cache counters.
+func (c *TTLCache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Close stops the janitor, if any. The cache stays usable.
+func (c *TTLCache[K, V]) Close() {
+	c.closeOnce.Do(func() { close(c.stop) })
+}
+
+func (c *TTLCache[K, V]) janitor() {
+	t := c.clock.NewTicker(c.janitorInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			c.sweep()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// sweep removes all expired entries.
+func (c *TTLCache[K, V]) sweep() {
+	now := c.clock.Now()
+	var expired []*item[K, V]
+	c.mu.Lock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if it := el.Value.(*item[K, V]); it.expired(now) {
+			c.removeLocked(el)
+			expired = append(expired, it)
+		}
+		el = next
+	}
+	c.stats.Expirations += uint64(len(expired))
+	c.mu.Unlock()
+	for _, it := range expired {
+		c.evicted(it, Expired)
+	}
+}
+
+func (c *TTLCache[K, V]) removeLocked(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.data, el.Value.(*item[K, V]).key)
+}
+
+func (c *TTLCache[K, V]) evicted(it *item[K, V], reason EvictReason) {
+	if c.onEvict != nil && !it.negative {
+		c.onEvict(it.key, it.val, reason)
+	}
+}
@@ -2,9 +2,12 @@ package cache
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
 )
 
 // Test that Get on non-existent key returns miss.
@@ -31,12 +34,14 @@ func TestTTLCache_Set_Then_Get(t *testing.T) {
 
 // Test that entries expire after TTL.
 func TestTTLCache_Expiration(t *testing.T) {
-	c := New[string, int](10 * time.Millisecond)
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := New(10*time.Second, WithClock[string, int](clk))
 	c.Set("a", 1)
+	clk.Advance(10 * time.Second)
 	if _, ok := c.Get("a"); !ok {
 		t.Fatalf("expected hit before expiry")
 	}
-	time.Sleep(20 * time.Millisecond)
+	clk.Advance(time.Nanosecond)
 	if _, ok := c.Get("a"); ok {
 		t.Fatalf("expected expired entry to miss")
 	}
@@ -46,7 +51,7 @@ func TestTTLCache_Expiration(t *testing.T) {
 func TestTTLCache_GetOrSet_Success(t *testing.T) {
 	c := New[string, int](time.Second)
 	calls := 0
-	supplier := func() (int, error) { calls++; return 99, nil }
+	supplier := func() (int, time.Duration, error) { calls++; return 99, 0, nil }
 	v, err := c.GetOrSet("k", supplier)
 	if err != nil || v != 99 {
 		t.Fatalf("unexpected: v=%v err=%v", v, err)
@@ -68,7 +73,7 @@ func TestTTLCache_GetOrSet_Success(t *testing.T) {
 func TestTTLCache_GetOrSet_Error(t *testing.T) {
 	c := New[string, int](time.Second)
 	boom := errors.New("boom")
-	_, err := c.GetOrSet("k", func() (int, error) { return 0, boom })
+	_, err := c.GetOrSet("k", func() (int, time.Duration, error) { return 0, 0, boom })
 	if err == nil {
 		t.Fatalf("expected error from supplier")
 	}
@@ -83,25 +88,26 @@ func TestTTLCache_Concurrent_GetOrSet(t *testing.T) {
 	c := New[string, int](time.Second)
 	var mu sync.Mutex
 	calls := 0
-	supplier := func() (int, error) {
+	supplier := func() (int, time.Duration, error) {
 		mu.Lock()
 		calls++
 		mu.Unlock()
-		// small delay to widen race window
-		time.Sleep(2 * time.Millisecond)
-		return 123, nil
+		return 123, 0, nil
 	}
 	var wg sync.WaitGroup
 	workers := 50
+	start := make(chan struct{})
 	wg.Add(workers)
 	for i := 0; i < workers; i++ {
 		go func() {
 			defer wg.Done()
+			<-start // release every worker at once to widen the race window
 			if v, err := c.GetOrSet("key", supplier); err != nil || v != 123 {
 				t.Errorf("unexpected: v=%v err=%v", v, err)
 			}
 		}()
 	}
+	close(start)
 	wg.Wait()
 	// final value should be present and correct
 	if v, ok := c.Get("key"); !ok || v != 123 {
@@ -111,3 +117,179 @@ func TestTTLCache_Concurrent_GetOrSet(t *testing.T) {
 		t.Fatalf("supplier calls out of expected range: %d", calls)
 	}
 }
+
+func TestTTLCache_LRUEviction(t *testing.T) {
+	var mu sync.Mutex
+	var evicted []string
+	c := New[string, int](time.Minute,
+		WithMaxEntries[string, int](2),
+		WithEvictCallback(func(k string, v int, r EvictReason) {
+			mu.Lock()
+			evicted = append(evicted, k+":"+r.String())
+			mu.Unlock()
+		}))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a") // b is now least recently used
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to survive")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+	c.Set("a", 10) // replacing an entry does not evict
+	if c.Len() != 2 || len(evicted) != 1 || evicted[0] != "b:capacity" {
+		t.Fatalf("unexpected evictions: %v", evicted)
+	}
+	st := c.Stats()
+	if st.Evictions != 1 || st.Hits != 2 || st.Misses != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestTTLCache_JanitorSweepsExpired(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	expired := make(chan string, 10)
+	c := New(20*time.Second,
+		WithClock[string, int](clk),
+		WithJanitor[string, int](10*time.Second),
+		WithEvictCallback(func(k string, v int, r EvictReason) {
+			if r == Expired {
+				expired <- k
+			}
+		}))
+	defer c.Close()
+	c.Set("a", 1)
+	c.Set("b", 2)
+	clk.BlockUntil(1) // janitor ticker
+	clk.Advance(10 * time.Second)
+	clk.Advance(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-expired:
+		case <-time.After(time.Second):
+			t.Fatalf("janitor did not sweep expired entries")
+		}
+	}
+	if c.Len() != 0 || c.Stats().Expirations != 2 {
+		t.Fatalf("expected empty cache, got len=%d stats=%+v", c.Len(), c.Stats())
+	}
+	c.Close() // idempotent
+}
+
+func TestTTLCache_ConcurrentBounded(t *testing.T) {
+	c := New[int, int](time.Millisecond,
+		WithMaxEntries[int, int](16),
+		WithJanitor[int, int](time.Millisecond))
+	defer c.Close()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				c.Set(w*1000+i, i)
+				c.Get(w*1000 + i/2)
+				_ = c.Len()
+				_ = c.Stats()
+			}
+		}(w)
+	}
+	wg.Wait()
+	if c.Len() > 16 {
+		t.Fatalf("expected at most 16 entries, got %d", c.Len())
+	}
+}
+
+func TestTTLCache_SetWithTTL(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := New(10*time.Second, WithClock[string, int](clk))
+	c.SetWithTTL("short", 1, time.Second)
+	c.SetWithTTL("forever", 2, NoExpiration)
+	c.Set("default", 3)
+	c.SetWithTTL("computed", 4, -time.Nanosecond) // a computed TTL gone negative
+
+	clk.Advance(2 * time.Second)
+	if _, ok := c.Get("short"); ok {
+		t.Fatalf("expected short TTL to expire")
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Fatalf("expected default TTL to still hold")
+	}
+	clk.Advance(24 * time.Hour)
+	if v, ok := c.Get("forever"); !ok || v != 2 {
+		t.Fatalf("expected entry without expiry, got %v ok=%v", v, ok)
+	}
+	if _, ok := c.Get("computed"); ok {
+		t.Fatalf("expected a negative TTL to mean the default, not no expiry")
+	}
+}
+
+func TestTTLCache_Lookup(t *testing.T) {
+	c := New[string, int](time.Minute)
+	c.Set("k", 1)
+	c.SetNegative("gone", time.Minute)
+	if v, found, negative := c.Lookup("k"); !found || negative || v != 1 {
+		t.Fatalf("expected a hit, got %v found=%v negative=%v", v, found, negative)
+	}
+	if _, found, negative := c.Lookup("gone"); found || !negative {
+		t.Fatalf("expected a negative entry, got found=%v negative=%v", found, negative)
+	}
+	if _, found, negative := c.Lookup("missing"); found || negative {
+		t.Fatalf("expected a miss, got found=%v negative=%v", found, negative)
+	}
+}
+
+func TestTTLCache_GetOrSet_TTLAndNegative(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := New(10*time.Second, WithClock[string, int](clk))
+
+	calls := 0
+	supplier := func() (int, time.Duration, error) { calls++; return 5, time.Hour, nil }
+	c.GetOrSet("k", supplier)
+	clk.Advance(30 * time.Minute)
+	if v, err := c.GetOrSet("k", supplier); err != nil || v != 5 || calls != 1 {
+		t.Fatalf("expected supplier TTL to apply, got v=%v err=%v calls=%d", v, err, calls)
+	}
+
+	missing := 0
+	unknown := func() (int, time.Duration, error) {
+		missing++
+		return 0, 5 * time.Second, fmt.Errorf("lookup: %w", ErrNotFound)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrSet("unknown", unknown); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if missing != 1 || !c.Negative("unknown") {
+		t.Fatalf("expected negative entry, got %d supplier calls", missing)
+	}
+	if _, ok := c.Get("unknown"); ok || c.Stats().NegativeHits == 0 {
+		t.Fatalf("expected negative entry to miss, stats=%+v", c.Stats())
+	}
+	clk.Advance(6 * time.Second)
+	c.GetOrSet("unknown", unknown)
+	if missing != 2 {
+		t.Fatalf("expected supplier to be retried after the negative TTL, got %d calls", missing)
+	}
+}
+
+func TestTTLCache_SetDefaultTTL(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := New(10*time.Second, WithClock[string, int](clk))
+	c.Set("old", 1)
+	c.SetDefaultTTL(time.Minute)
+	c.Set("new", 2)
+	clk.Advance(11 * time.Second)
+	if _, ok := c.Get("old"); ok {
+		t.Fatalf("expected existing entry to keep its expiry")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Fatalf("expected new entry to use the new default TTL")
+	}
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RedisConfig configures a RedisCache.
+type RedisConfig struct {
+	Addr     string // host:port
+	Password string // sent with AUTH if set
+	Prefix   string // prepended to every key, e.g. "btcprices:"
+	// Timeout bounds dialing and each command (default 250ms), so an
+	// unreachable server delays a request at most this long.
+	Timeout time.Duration
+	// RetryAfter is how long the in-memory fallback is used after a failure
+	// before the server is tried again (default 5s).
+	RetryAfter time.Duration
+	// OnError, if set, is called when the fallback is engaged.
+	OnError func(error)
+}
+
+const redisPoolSize = 8
+
+// RedisCache stores JSON-encoded values in Redis (or any server speaking its
+// protocol) with the TTL set server-side, so several processes share entries
+// and their expiry. While the server is unreachable it falls back to an
+// in-memory TTLCache, which every Set also writes to so it is warm.
+type RedisCache[V any] struct {
+	cfg       RedisConfig
+	ttl       atomic.Int64 // default TTL as a time.Duration
+	local     *TTLCache[string, V]
+	pool      chan *respConn
+	downUntil atomic.Int64 // unix nanos; the fallback is used until then
+}
+
+// NewRedis returns a cache backed by the server at cfg.Addr. It does not
+// connect until first use. opts configure the in-memory fallback, which
+// should be bounded (WithMaxEntries, WithJanitor) as it holds every entry
+// set while the server is unreachable; its clock (WithClock) also times
+// cfg.RetryAfter.
+func NewRedis[V any](cfg RedisConfig, ttl time.Duration, opts ...Option[string, V]) *RedisCache[V] {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 250 * time.Millisecond
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 5 * time.Second
+	}
+	c := &RedisCache[V]{
+		cfg:   cfg,
+		local: New(ttl, opts...),
+		pool:  make(chan *respConn, redisPoolSize),
+	}
+	c.ttl.Store(int64(ttl))
+	return c
+}
+
+// SetDefaultTTL changes the default TTL for entries stored from now on (see
+// TTLCache.SetDefaultTTL).
+func (c *RedisCache[V]) SetDefaultTTL(ttl time.Duration) {
+	if ttl > 0 {
+		c.ttl.Store(int64(ttl))
+		c.local.SetDefaultTTL(ttl)
+	}
+}
+
+// negativeMarker is stored for negatively cached keys; it is never valid JSON.
+const negativeMarker = "\x00"
+
+func (c *RedisCache[V]) Get(key string) (V, bool) {
+	v, found, _ := c.Lookup(key)
+	return v, found
+}
+
+// Lookup reads key, reporting whether it holds a value or a negative entry.
+func (c *RedisCache[V]) Lookup(key string) (v V, found, negative bool) {
+	if c.down() {
+		v, found = c.local.Get(key)
+		return v, found, !found && c.local.Negative(key)
+	}
+	reply, err := c.do("GET", c.cfg.Prefix+key)
+	if err != nil {
+		c.fail(err)
+		v, found = c.local.Get(key)
+		return v, found, !found && c.local.Negative(key)
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return v, false, false
+	}
+	if string(b) == negativeMarker {
+		return v, false, true
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, false, false
+	}
+	return v, true, false
+}
+
+func (c *RedisCache[V]) Set(key string, val V) {
+	c.SetWithTTL(key, val, 0)
+}
+
+// SetWithTTL stores val for ttl instead of the cache default (see TTLCache.SetWithTTL).
+func (c *RedisCache[V]) SetWithTTL(key string, val V, ttl time.Duration) {
+	c.local.SetWithTTL(key, val, ttl)
+	b, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	c.store(key, string(b), ttl)
+}
+
+// SetNegative records that key does not exist for ttl (see TTLCache.SetNegative).
+func (c *RedisCache[V]) SetNegative(key string, ttl time.Duration) {
+	c.local.SetNegative(key, ttl)
+	c.store(key, negativeMarker, ttl)
+}
+
+func (c *RedisCache[V]) store(key, raw string, ttl time.Duration) {
+	if c.down() {
+		return
+	}
+	args := []string{"SET", c.cfg.Prefix + key, raw}
+	switch {
+	case ttl == NoExpiration:
+	case ttl <= 0:
+		args = append(args, "PX", strconv.FormatInt(time.Duration(c.ttl.Load()).Milliseconds(), 10))
+	default:
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	if _, err := c.do(args...); err != nil {
+		c.fail(err)
+	}
+}
+
+// GetOrSet returns existing value if fresh, otherwise determines and sets using supplier.
+// While key is negatively cached it returns ErrNotFound.
+func (c *RedisCache[V]) GetOrSet(key string, supplier Supplier[V]) (V, error) {
+	v, found, negative := c.Lookup(key)
+	if found {
+		return v, nil
+	}
+	var zero V
+	if negative {
+		return zero, ErrNotFound
+	}
+	v, ttl, err := supplier()
+	if errors.Is(err, ErrNotFound) {
+		c.SetNegative(key, ttl)
+		return zero, err
+	}
+	if err != nil {
+		return zero, err
+	}
+	c.SetWithTTL(key, v, ttl)
+	return v, nil
+}
+
+// Close closes idle connections and stops the fallback's janitor.
+func (c *RedisCache[V]) Close() {
+	c.local.Close()
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (c *RedisCache[V]) down() bool {
+	return c.local.clock.Now().UnixNano() < c.downUntil.Load()
+}
+
+func (c *RedisCache[V]) fail(err error) {
+	c.downUntil.Store(c.local.clock.Now().Add(c.cfg.RetryAfter).UnixNano())
+	if c.cfg.OnError != nil {
+		c.cfg.OnError(err)
+	}
+}
+
+// do runs one command on a pooled connection. Connections are discarded
+// after transport errors; error replies keep them.
+func (c *RedisCache[V]) do(args ...string) (any, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(c.cfg.Timeout, args...)
+	if err != nil && !isRedisError(err) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (c *RedisCache[V]) conn() (*respConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	conn, err := dialRESP(c.cfg.Addr, c.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Password != "" {
+		if _, err := conn.do(c.cfg.Timeout, "AUTH", c.cfg.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+var (
+	_ Cache[string, int] = (*TTLCache[string, int])(nil)
+	_ Cache[string, int] = (*RedisCache[int])(nil)
+)
+
+// fakeRedis is an in-process server speaking enough RESP for RedisCache:
+// GET, SET (optionally with PX), and AUTH. Expiry follows clock.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	clock    *clocktest.Fake
+
+	mu   sync.Mutex
+	data map[string]entry[string]
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, clock: clocktest.NewFake(time.Unix(0, 0)), data: make(map[string]entry[string])}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		var reply string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authed = len(args) == 2 && args[1] == f.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "GET":
+			f.mu.Lock()
+			e, ok := f.data[args[1]]
+			f.mu.Unlock()
+			if !ok || e.expired(f.clock.Now()) {
+				reply = "$-1\r\n"
+			} else {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(e.val), e.val)
+			}
+		case cmd == "SET" && (len(args) == 3 || len(args) == 5 && strings.ToUpper(args[3]) == "PX"):
+			e := entry[string]{val: args[2]}
+			if len(args) == 5 {
+				ms, _ := strconv.Atoi(args[4])
+				e.expiresAt = f.clock.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			f.mu.Lock()
+			f.data[args[1]] = e
+			f.mu.Unlock()
+			reply = "+OK\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' || n < 1 {
+		return nil, errors.New("bad command")
+	}
+	args := make([]string, n)
+	for i := range args {
+		hdr, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+type quote struct {
+	Price float64
+	At    time.Time
+}
+
+func TestRedisCache_SharedBetweenInstances(t *testing.T) {
+	srv := startFakeRedis(t, "s3cret")
+	cfg := RedisConfig{Addr: srv.addr(), Password: "s3cret", Prefix: "test:"}
+	a := NewRedis[quote](cfg, 100*time.Millisecond)
+	b := NewRedis[quote](cfg, 100*time.Millisecond)
+	defer a.Close()
+	defer b.Close()
+
+	want := quote{Price: 52000.12, At: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	a.Set("XXBTZUSD", want)
+	got, ok := b.Get("XXBTZUSD")
+	if !ok || got.Price != want.Price || !got.At.Equal(want.At) {
+		t.Fatalf("expected shared value, got %+v ok=%v", got, ok)
+	}
+	srv.mu.Lock()
+	_, stored := srv.data["test:XXBTZUSD"]
+	srv.mu.Unlock()
+	if !stored {
+		t.Fatalf("expected prefixed key on the server")
+	}
+
+	srv.clock.Advance(150 * time.Millisecond)
+	if _, ok := b.Get("XXBTZUSD"); ok {
+		t.Fatalf("expected server-side expiry")
+	}
+}
+
+func TestRedisCache_FallsBackWhenUnreachable(t *testing.T) {
+	srv := startFakeRedis(t, "")
+	var failures int
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := NewRedis(RedisConfig{
+		Addr:       srv.addr(),
+		Timeout:    50 * time.Millisecond,
+		RetryAfter: time.Minute,
+		OnError:    func(error) { failures++ },
+	}, time.Hour, WithClock[string, int](clk))
+	defer c.Close()
+
+	c.Set("a", 1)
+	srv.ln.Close()
+	c.Close() // drop pooled connections so the next command has to dial
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected fallback hit, got %v ok=%v", v, ok)
+	}
+	c.Set("b", 2)
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Fatalf("expected fallback write, got %v ok=%v", v, ok)
+	}
+	if failures != 1 {
+		t.Fatalf("expected server to be skipped after one failure, got %d failures", failures)
+	}
+	clk.Advance(time.Minute)
+	c.Get("a")
+	if failures != 2 {
+		t.Fatalf("expected server to be tried again after RetryAfter, got %d failures", failures)
+	}
+}
+
+func TestRedisCache_FallbackIsBounded(t *testing.T) {
+	srv := startFakeRedis(t, "")
+	c := NewRedis(RedisConfig{Addr: srv.addr(), Timeout: 50 * time.Millisecond, RetryAfter: time.Hour}, time.Minute,
+		WithMaxEntries[string, int](2))
+	defer c.Close()
+	srv.ln.Close()
+
+	for i, k := range []string{"a", "b", "c"} {
+		c.Set(k, i)
+	}
+	if n := c.local.Len(); n != 2 {
+		t.Fatalf("expected the fallback bounded to 2 entries, got %d", n)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected the least recently used entry evicted")
+	}
+}
+
+func TestRedisCache_GetOrSet(t *testing.T) {
+	srv := startFakeRedis(t, "")
+	c := NewRedis[int](RedisConfig{Addr: srv.addr()}, time.Minute)
+	defer c.Close()
+	calls := 0
+	supplier := func() (int, time.Duration, error) { calls++; return 7, 0, nil }
+	for i := 0; i < 2; i++ {
+		if v, err := c.GetOrSet("k", supplier); err != nil || v != 7 {
+			t.Fatalf("unexpected %v %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected supplier once, got %d", calls)
+	}
+}
+
+func TestRedisCache_PerKeyTTLAndNegative(t *testing.T) {
+	srv := startFakeRedis(t, "")
+	cfg := RedisConfig{Addr: srv.addr()}
+	a := NewRedis[int](cfg, time.Minute)
+	b := NewRedis[int](cfg, time.Minute)
+	defer a.Close()
+	defer b.Close()
+
+	a.SetWithTTL("short", 1, 30*time.Millisecond)
+	a.SetWithTTL("forever", 2, NoExpiration)
+	calls := 0
+	missing := func() (int, time.Duration, error) { calls++; return 0, time.Minute, ErrNotFound }
+	if _, err := a.GetOrSet("unknown", missing); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	// the negative entry is shared
+	if _, err := b.GetOrSet("unknown", missing); !errors.Is(err, ErrNotFound) || calls != 1 {
+		t.Fatalf("expected shared negative entry, got err=%v calls=%d", err, calls)
+	}
+	if _, ok := b.Get("unknown"); ok {
+		t.Fatalf("expected negative entry to miss")
+	}
+
+	srv.clock.Advance(50 * time.Millisecond)
+	if _, ok := b.Get("short"); ok {
+		t.Fatalf("expected per-key TTL to expire")
+	}
+	if v, ok := b.Get("forever"); !ok || v != 2 {
+		t.Fatalf("expected entry without expiry, got %v ok=%v", v, ok)
+	}
+}
@@ -0,0 +1,181 @@
+// Package certs serves TLS certificates loaded from files and reloads them
+// when the files change on disk, e.g. when cert-manager rotates a secret.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Reloader.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if set, is a PEM bundle client certificates are verified
+	// against (mTLS).
+	ClientCAFile string
+	// ClientAuth applies when ClientCAFile is set (default
+	// tls.RequireAndVerifyClientCert).
+	ClientAuth tls.ClientAuthType
+	// Interval is how often the files are checked for changes (default 10s).
+	Interval time.Duration
+}
+
+// Reloader holds the current certificate and client CA pool. Handshakes
+// always use the latest files that loaded successfully; a broken update is
+// logged and the previous certificate stays in use.
+type Reloader struct {
+	cfg Config
+	log *slog.Logger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	pool  *x509.CertPool
+	stamp string // modification times and sizes of the loaded files
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New loads the files and starts watching them until Close.
+func New(cfg Config, logger *slog.Logger) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("certs: cert and key file are required")
+	}
+	if cfg.ClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	r := &Reloader{cfg: cfg, log: logger, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	go r.watch()
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that picks up reloaded files on
+// every handshake. GetCertificate is set as well, so that
+// http.Server.ListenAndServeTLS("", "") accepts the configuration.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetCertificate:     r.getCertificate,
+		GetConfigForClient: r.configForClient,
+	}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *Reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.pool != nil {
+		c.ClientCAs = r.pool
+		c.ClientAuth = r.cfg.ClientAuth
+	}
+	return c, nil
+}
+
+// Reload loads the files now. On error the current certificate is kept.
+func (r *Reloader) Reload() error {
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("certs: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("certs: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("certs: no certificates in %s", r.cfg.ClientCAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.pool, r.stamp = &cert, pool, stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// Close stops watching the files.
+func (r *Reloader) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
+
+func (r *Reloader) watch() {
+	defer close(r.done)
+	t := time.NewTicker(r.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.reloadIfChanged()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// reloadIfChanged reloads when any file's modification time or size changed.
+func (r *Reloader) reloadIfChanged() {
+	stamp, err := r.fileStamp()
+	r.mu.RLock()
+	unchanged := stamp == r.stamp
+	r.mu.RUnlock()
+	if err == nil && unchanged {
+		return
+	}
+	if err == nil {
+		err = r.Reload()
+	}
+	if err != nil {
+		// a rotation may be half done; retry on the next tick
+		r.log.Error("certificate reload failed, keeping current certificate", "err", err)
+		return
+	}
+	r.log.Info("certificate reloaded", "cert", r.cfg.CertFile)
+}
+
+func (r *Reloader) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("certs: %w", err)
+		}
+		fmt.Fprintf(&b, "%d/%d;", fi.ModTime().UnixNano(), fi.Size())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,208 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newCert issues a certificate for cn, signed by parent or self-signed.
+func newCert(t *testing.T, cn string, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	if err := os.WriteFile(certFile, c.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, c.keyPEM(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCert) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// serve accepts TLS connections with cfg and completes their handshakes.
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Write([]byte("x"))
+				conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// dial returns the server certificate's common name, or the handshake error.
+func dial(addr string, roots *x509.CertPool, client *tls.Certificate) (string, error) {
+	cfg := &tls.Config{RootCAs: roots}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{*client}
+	}
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	// with TLS 1.3 a rejected client certificate surfaces on the first read
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func discard() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func TestReloader_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca := newCert(t, "ca", true, nil)
+	newCert(t, "first", false, ca).write(t, certFile, keyFile)
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, Interval: time.Hour}, discard())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer r.Close()
+	addr := serve(t, r.TLSConfig())
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	if cn, err := dial(addr, roots, nil); err != nil || cn != "first" {
+		t.Fatalf("expected first cert, got %q err=%v", cn, err)
+	}
+
+	// a half-written rotation keeps the current certificate
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.reloadIfChanged()
+	if cn, err := dial(addr, roots, nil); err != nil || cn != "first" {
+		t.Fatalf("expected first cert kept, got %q err=%v", cn, err)
+	}
+
+	newCert(t, "second", false, ca).write(t, certFile, keyFile)
+	r.reloadIfChanged()
+	if cn, err := dial(addr, roots, nil); err != nil || cn != "second" {
+		t.Fatalf("expected rotated cert, got %q err=%v", cn, err)
+	}
+
+	// Go before 1.24 only accepts ListenAndServeTLS("", "") with GetCertificate
+	c, err := r.TLSConfig().GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	if leaf, err := x509.ParseCertificate(c.Certificate[0]); err != nil || leaf.Subject.CommonName != "second" {
+		t.Fatalf("expected GetCertificate to return the rotated cert, got err=%v", err)
+	}
+}
+
+func TestReloader_ClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	ca := newCert(t, "ca", true, nil)
+	newCert(t, "server", false, ca).write(t, certFile, keyFile)
+	if err := os.WriteFile(caFile, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, discard())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer r.Close()
+	addr := serve(t, r.TLSConfig())
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	if _, err := dial(addr, roots, nil); err == nil {
+		t.Fatalf("expected handshake without client certificate to fail")
+	}
+	stranger := newCert(t, "stranger", false, newCert(t, "other-ca", true, nil)).tlsCert(t)
+	if _, err := dial(addr, roots, &stranger); err == nil {
+		t.Fatalf("expected client certificate from another CA to be rejected")
+	}
+	client := newCert(t, "internal", false, ca).tlsCert(t)
+	if _, err := dial(addr, roots, &client); err != nil {
+		t.Fatalf("expected client certificate to be accepted, got %v", err)
+	}
+}
+
+func TestNew_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(Config{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}, discard()); err == nil {
+		t.Fatalf("expected error for missing files")
+	}
+}
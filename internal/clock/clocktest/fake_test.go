@@ -0,0 +1,49 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_AfterAndTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	after := f.After(2 * time.Second)
+	tick := f.NewTicker(time.Second)
+	defer tick.Stop()
+
+	f.Advance(time.Second)
+	select {
+	case <-after:
+		t.Fatalf("timer fired early")
+	default:
+	}
+	if got := <-tick.C(); !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected tick time %v", got)
+	}
+
+	f.Advance(time.Second)
+	if got := <-after; !got.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("unexpected timer time %v", got)
+	}
+	<-tick.C()
+	if !f.Now().Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("unexpected now %v", f.Now())
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Minute)
+		close(done)
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("waiter was not released")
+	}
+}
@@ -0,0 +1,752 @@
+// Package config loads the server configuration from defaults, an optional
+// YAML or JSON file, environment variables and command-line flags, in that
+// order of increasing precedence, and validates it.
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"bitcoin-prices/internal/decimal"
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// Config is the complete server configuration.
+type Config struct {
+	Port      int             `yaml:"port" json:"port"`
+	Server    ServerConfig    `yaml:"server" json:"server"`
+	TLS       TLSConfig       `yaml:"tls" json:"tls"`
+	Auth      AuthConfig      `yaml:"auth" json:"auth"`
+	RateLimit RateLimitConfig `yaml:"ratelimit" json:"ratelimit"`
+	Log       LogConfig       `yaml:"log" json:"log"`
+	Admin     AdminConfig     `yaml:"admin" json:"admin"`
+	Pairs     PairsConfig     `yaml:"pairs" json:"pairs"`
+	Cache     CacheConfig     `yaml:"cache" json:"cache"`
+	Kraken    KrakenConfig    `yaml:"kraken" json:"kraken"`
+	LTP       LTPConfig       `yaml:"ltp" json:"ltp"`
+	Ready     ReadyConfig     `yaml:"ready" json:"ready"`
+	Convert   ConvertConfig   `yaml:"convert" json:"convert"`
+	Guard     GuardConfig     `yaml:"guard" json:"guard"`
+	Redis     RedisConfig     `yaml:"redis" json:"redis"`
+	Alerts    AlertsConfig    `yaml:"alerts" json:"alerts"`
+	Tracing   TracingConfig   `yaml:"tracing" json:"tracing"`
+}
+
+type ServerConfig struct {
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" json:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	MaxHeaderBytes    int      `yaml:"max_header_bytes" json:"max_header_bytes"`
+	// HandlerTimeout is the request deadline; RouteTimeouts overrides it by
+	// path, with 0 meaning no deadline (streaming routes).
+	HandlerTimeout Duration      `yaml:"handler_timeout" json:"handler_timeout"`
+	RouteTimeouts  Map[Duration] `yaml:"route_timeouts" json:"route_timeouts"`
+	// TrustedProxies are the CIDRs or IPs of reverse proxies whose
+	// Forwarded, X-Forwarded-For and X-Real-IP headers are believed.
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set. With Port 0 the
+// main port serves HTTPS only; otherwise it keeps serving plain HTTP and
+// HTTPS is served on Port.
+type TLSConfig struct {
+	CertFile       string   `yaml:"cert_file" json:"cert_file"`
+	KeyFile        string   `yaml:"key_file" json:"key_file"`
+	ClientCAFile   string   `yaml:"client_ca_file" json:"client_ca_file"`
+	ClientAuth     string   `yaml:"client_auth" json:"client_auth"` // require or verify_if_given
+	Port           int      `yaml:"port" json:"port"`
+	ReloadInterval Duration `yaml:"reload_interval" json:"reload_interval"`
+}
+
+// Enabled reports whether HTTPS is configured.
+func (t TLSConfig) Enabled() bool { return t.CertFile != "" }
+
+// TLSAddr is the HTTPS listen address when it differs from Addr, or "".
+func (c Config) TLSAddr() string {
+	if !c.TLS.Enabled() || c.TLS.Port == 0 {
+		return ""
+	}
+	return ":" + strconv.Itoa(c.TLS.Port)
+}
+
+// AuthConfig enables API key authentication when Keys is not empty.
+type AuthConfig struct {
+	Keys APIKeys `yaml:"keys" json:"keys"`
+}
+
+// APIKey is a client key, stored as the SHA-256 digest of the key.
+type APIKey struct {
+	ID   string `yaml:"id" json:"id"`
+	Hash string `yaml:"hash" json:"hash"` // "sha256:<hex>"
+	// Scopes are the routes the key may call, as mounted (e.g.
+	// "/api/v1/ltp"); a trailing "*" matches a prefix. Empty allows all.
+	Scopes      []string `yaml:"scopes" json:"scopes"`
+	Quota       int      `yaml:"quota" json:"quota"` // requests per QuotaWindow; 0: unlimited
+	QuotaWindow Duration `yaml:"quota_window" json:"quota_window"`
+}
+
+// Digest decodes Hash.
+func (k APIKey) Digest() ([sha256.Size]byte, error) {
+	var d [sha256.Size]byte
+	h, ok := strings.CutPrefix(k.Hash, "sha256:")
+	b, err := hex.DecodeString(h)
+	if !ok || err != nil || len(b) != sha256.Size {
+		return d, fmt.Errorf("hash must be sha256:<64 hex digits>")
+	}
+	copy(d[:], b)
+	return d, nil
+}
+
+// APIKeys is a list of keys. In environment variables and flags it is
+// written as a JSON array.
+type APIKeys []APIKey
+
+func (k *APIKeys) Set(v string) error {
+	var keys []APIKey
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&keys); err != nil {
+		return fmt.Errorf("invalid key list (want a JSON array): %w", err)
+	}
+	*k = keys
+	return nil
+}
+
+func (k APIKeys) String() string {
+	if len(k) == 0 {
+		return `""`
+	}
+	b, _ := json.Marshal(k)
+	return string(b)
+}
+
+// RateLimitConfig limits requests per client (API key, or IP without one)
+// with token buckets. Rate 0 disables limiting unless a route sets its own.
+type RateLimitConfig struct {
+	Rate       float64         `yaml:"rate" json:"rate"`   // requests per second
+	Burst      int             `yaml:"burst" json:"burst"` // 0: rate rounded up
+	Routes     Map[RouteLimit] `yaml:"routes" json:"routes"`
+	MaxClients int             `yaml:"max_clients" json:"max_clients"` // buckets tracked
+}
+
+// RouteLimit is a token bucket rate and burst.
+type RouteLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" json:"level"`
+	Format string `yaml:"format" json:"format"` // text or json
+	// AccessSample is the share of successful requests logged by route, 0
+	// logging none. Failed requests are always logged; other API routes
+	// too, while probes and /metrics are not unless listed.
+	AccessSample Map[float64] `yaml:"access_sample" json:"access_sample"`
+}
+
+type AdminConfig struct {
+	Token string `yaml:"token" json:"token"` // enables /api/admin endpoints
+}
+
+type PairsConfig struct {
+	Enabled []string `yaml:"enabled" json:"enabled"` // empty: all supported
+}
+
+type CacheConfig struct {
+	TTL           Duration `yaml:"ttl" json:"ttl"`
+	MaxEntries    int      `yaml:"max_entries" json:"max_entries"`
+	StateFile     string   `yaml:"state_file" json:"state_file"`
+	StateInterval Duration `yaml:"state_interval" json:"state_interval"`
+}
+
+type KrakenConfig struct {
+	BaseURL   string   `yaml:"base_url" json:"base_url"`
+	Timeout   Duration `yaml:"timeout" json:"timeout"` // per attempt
+	Retries   int      `yaml:"retries" json:"retries"`
+	BatchSize int      `yaml:"batch_size" json:"batch_size"`
+}
+
+type LTPConfig struct {
+	StaleAfter        Duration `yaml:"stale_after" json:"stale_after"`
+	SnapshotRetention Duration `yaml:"snapshot_retention" json:"snapshot_retention"`
+}
+
+type ReadyConfig struct {
+	MaxClockSkew Duration `yaml:"max_clock_skew" json:"max_clock_skew"`
+}
+
+type ConvertConfig struct {
+	Rounding string `yaml:"rounding" json:"rounding"`
+}
+
+type GuardConfig struct {
+	MaxDeviation float64      `yaml:"max_deviation" json:"max_deviation"` // percent
+	PerPair      Map[float64] `yaml:"per_pair" json:"per_pair"`
+	Window       Duration     `yaml:"window" json:"window"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr" json:"addr"`
+	Password string `yaml:"password" json:"password"`
+	Prefix   string `yaml:"prefix" json:"prefix"`
+}
+
+type AlertsConfig struct {
+	File           string `yaml:"file" json:"file"`
+	DeadLetterFile string `yaml:"dead_letter_file" json:"dead_letter_file"`
+	// DeadLetterMaxBytes is the size at which the dead-letter file is rotated.
+	DeadLetterMaxBytes int      `yaml:"dead_letter_max_bytes" json:"dead_letter_max_bytes"`
+	WebhookAttempts    int      `yaml:"webhook_attempts" json:"webhook_attempts"`
+	PollInterval       Duration `yaml:"poll_interval" json:"poll_interval"`
+	// AllowedNetworks may receive webhooks although they are not public.
+	AllowedNetworks []string `yaml:"allowed_networks" json:"allowed_networks"`
+}
+
+// TracingConfig exports OpenTelemetry spans to stdout or an OTLP/HTTP
+// collector.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" json:"exporter"` // none, stdout or otlp
+	Endpoint    string  `yaml:"endpoint" json:"endpoint"` // OTLP/HTTP collector URL
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+	ServiceName string  `yaml:"service_name" json:"service_name"`
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		Port: 8080,
+		Server: ServerConfig{
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(10 * time.Second),
+			WriteTimeout:      Duration(10 * time.Second),
+			IdleTimeout:       Duration(30 * time.Second),
+			MaxHeaderBytes:    1 << 20,
+			HandlerTimeout:    Duration(8 * time.Second),
+		},
+		TLS:       TLSConfig{ClientAuth: "require", ReloadInterval: Duration(10 * time.Second)},
+		RateLimit: RateLimitConfig{MaxClients: 10000},
+		Log:       LogConfig{Level: "info", Format: "text"},
+		Cache:     CacheConfig{TTL: Duration(10 * time.Second), MaxEntries: 10000, StateInterval: Duration(time.Minute)},
+		Kraken:    KrakenConfig{BaseURL: "https://api.kraken.com", Timeout: Duration(2 * time.Second), Retries: 2, BatchSize: 20},
+		LTP:       LTPConfig{StaleAfter: Duration(time.Minute), SnapshotRetention: Duration(5 * time.Minute)},
+		Ready:     ReadyConfig{MaxClockSkew: Duration(5 * time.Second)},
+		Convert:   ConvertConfig{Rounding: string(decimal.HalfEven)},
+		Guard:     GuardConfig{MaxDeviation: 20, Window: Duration(time.Minute)},
+		Redis:     RedisConfig{Prefix: "btcprices:"},
+		Alerts:    AlertsConfig{File: "alerts.json", DeadLetterMaxBytes: 10 << 20, WebhookAttempts: 4, PollInterval: Duration(30 * time.Second)},
+		Tracing:   TracingConfig{Exporter: "none", SampleRatio: 1, ServiceName: "bitcoin-prices"},
+	}
+}
+
+// Addr is the listen address for the HTTP server.
+func (c Config) Addr() string { return ":" + strconv.Itoa(c.Port) }
+
+// setting binds one configuration value to its environment variable and flag.
+// The flag name is the value's path in the config file, e.g. "cache.ttl".
+type setting struct {
+	key  string
+	env  string
+	help string
+	ptr  func(*Config) any
+}
+
+var settings = []setting{
+	{"port", "PORT", "HTTP port", func(c *Config) any { return &c.Port }},
+	{"server.read_header_timeout", "SERVER_READ_HEADER_TIMEOUT", "time to read request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"server.read_timeout", "SERVER_READ_TIMEOUT", "time to read a whole request (0: none)", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"server.write_timeout", "SERVER_WRITE_TIMEOUT", "time to write a response (0: none)", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "SERVER_IDLE_TIMEOUT", "keep-alive idle time (0: read timeout)", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"server.max_header_bytes", "SERVER_MAX_HEADER_BYTES", "largest accepted request header size", func(c *Config) any { return &c.Server.MaxHeaderBytes }},
+	{"server.handler_timeout", "SERVER_HANDLER_TIMEOUT", "request deadline for API handlers", func(c *Config) any { return &c.Server.HandlerTimeout }},
+	{"server.route_timeouts", "SERVER_ROUTE_TIMEOUTS", "per-route deadlines, e.g. /api/v1/ohlc=15s (0: none)", func(c *Config) any { return &c.Server.RouteTimeouts }},
+	{"server.trusted_proxies", "SERVER_TRUSTED_PROXIES", "comma-separated CIDRs of proxies whose forwarding headers are trusted (empty: none)", func(c *Config) any { return &c.Server.TrustedProxies }},
+	{"tls.cert_file", "TLS_CERT_FILE", "PEM certificate (chain) for HTTPS (empty: plain HTTP)", func(c *Config) any { return &c.TLS.CertFile }},
+	{"tls.key_file", "TLS_KEY_FILE", "PEM private key for HTTPS", func(c *Config) any { return &c.TLS.KeyFile }},
+	{"tls.client_ca_file", "TLS_CLIENT_CA_FILE", "PEM CA bundle client certificates are verified against (empty: no mTLS)", func(c *Config) any { return &c.TLS.ClientCAFile }},
+	{"tls.client_auth", "TLS_CLIENT_AUTH", "client certificate policy with a CA bundle: require or verify_if_given", func(c *Config) any { return &c.TLS.ClientAuth }},
+	{"tls.port", "TLS_PORT", "separate HTTPS port (0: HTTPS on port instead of HTTP)", func(c *Config) any { return &c.TLS.Port }},
+	{"tls.reload_interval", "TLS_RELOAD_INTERVAL", "how often certificate files are checked for changes", func(c *Config) any { return &c.TLS.ReloadInterval }},
+	{"auth.keys", "AUTH_KEYS", "API keys as a JSON array of {id, hash, scopes, quota, quota_window} (empty: no auth)", func(c *Config) any { return &c.Auth.Keys }},
+	{"ratelimit.rate", "RATELIMIT_RATE", "requests per second per client (0: unlimited)", func(c *Config) any { return &c.RateLimit.Rate }},
+	{"ratelimit.burst", "RATELIMIT_BURST", "requests a client may make at once (0: rate rounded up)", func(c *Config) any { return &c.RateLimit.Burst }},
+	{"ratelimit.routes", "RATELIMIT_ROUTES", "per-route limits, e.g. /api/v1/ohlc=1/5 (RATE[/BURST], 0: unlimited)", func(c *Config) any { return &c.RateLimit.Routes }},
+	{"ratelimit.max_clients", "RATELIMIT_MAX_CLIENTS", "most clients tracked at once, least recently seen are dropped", func(c *Config) any { return &c.RateLimit.MaxClients }},
+	{"log.level", "LOG_LEVEL", "log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log.format", "LOG_FORMAT", "log format: text or json", func(c *Config) any { return &c.Log.Format }},
+	{"log.access_sample", "LOG_ACCESS_SAMPLE", "share of successful requests logged per route, e.g. /api/health=0,/api/v1/ltp=0.1", func(c *Config) any { return &c.Log.AccessSample }},
+	{"admin.token", "ADMIN_TOKEN", "bearer token for /api/admin endpoints (empty: disabled)", func(c *Config) any { return &c.Admin.Token }},
+	{"pairs.enabled", "PAIRS_ENABLED", "comma-separated pairs to serve (empty: all supported)", func(c *Config) any { return &c.Pairs.Enabled }},
+	{"cache.ttl", "CACHE_TTL", "price cache TTL", func(c *Config) any { return &c.Cache.TTL }},
+	{"cache.max_entries", "CACHE_MAX_ENTRIES", "maximum entries per internal cache (0: unbounded)", func(c *Config) any { return &c.Cache.MaxEntries }},
+	{"cache.state_file", "CACHE_STATE_FILE", "file to save cache state to for warm restarts", func(c *Config) any { return &c.Cache.StateFile }},
+	{"cache.state_interval", "CACHE_STATE_INTERVAL", "interval between cache state saves", func(c *Config) any { return &c.Cache.StateInterval }},
+	{"kraken.base_url", "KRAKEN_BASE_URL", "Kraken API base URL", func(c *Config) any { return &c.Kraken.BaseURL }},
+	{"kraken.timeout", "KRAKEN_TIMEOUT", "Kraken HTTP timeout per attempt", func(c *Config) any { return &c.Kraken.Timeout }},
+	{"kraken.retries", "KRAKEN_RETRIES", "Kraken retries on 429/5xx", func(c *Config) any { return &c.Kraken.Retries }},
+	{"kraken.batch_size", "KRAKEN_BATCH_SIZE", "pairs per Kraken Ticker call for batch requests", func(c *Config) any { return &c.Kraken.BatchSize }},
+	{"ltp.stale_after", "LTP_STALE_AFTER", "last trade age flagged as stale (0: disabled)", func(c *Config) any { return &c.LTP.StaleAfter }},
+	{"ltp.snapshot_retention", "LTP_SNAPSHOT_RETENTION", "how long consistent snapshots can be retrieved", func(c *Config) any { return &c.LTP.SnapshotRetention }},
+	{"ready.max_clock_skew", "READY_MAX_CLOCK_SKEW", "largest tolerated clock skew to Kraken (0: disabled)", func(c *Config) any { return &c.Ready.MaxClockSkew }},
+	{"convert.rounding", "CONVERT_ROUNDING", "default rounding: half_even, half_up, down or up", func(c *Config) any { return &c.Convert.Rounding }},
+	{"guard.max_deviation", "PRICE_MAX_DEVIATION", "largest accepted price move in percent (0: disabled)", func(c *Config) any { return &c.Guard.MaxDeviation }},
+	{"guard.per_pair", "PRICE_MAX_DEVIATION_PAIRS", "per-pair overrides, e.g. BTC/USD=10,BTC/CHF=25", func(c *Config) any { return &c.Guard.PerPair }},
+	{"guard.window", "PRICE_GUARD_WINDOW", "how long the last accepted price is the reference", func(c *Config) any { return &c.Guard.Window }},
+	{"redis.addr", "REDIS_ADDR", "host:port of a shared Redis price cache", func(c *Config) any { return &c.Redis.Addr }},
+	{"redis.password", "REDIS_PASSWORD", "Redis password", func(c *Config) any { return &c.Redis.Password }},
+	{"redis.prefix", "REDIS_PREFIX", "Redis key prefix", func(c *Config) any { return &c.Redis.Prefix }},
+	{"alerts.file", "ALERTS_FILE", "file alerts are persisted to (empty: in memory only)", func(c *Config) any { return &c.Alerts.File }},
+	{"alerts.dead_letter_file", "ALERTS_DEAD_LETTER_FILE", "JSON lines file for undeliverable webhook events", func(c *Config) any { return &c.Alerts.DeadLetterFile }},
+	{"alerts.dead_letter_max_bytes", "ALERTS_DEAD_LETTER_MAX_BYTES", "size at which the dead-letter file is moved to <file>.1", func(c *Config) any { return &c.Alerts.DeadLetterMaxBytes }},
+	{"alerts.webhook_attempts", "ALERTS_WEBHOOK_ATTEMPTS", "delivery attempts per webhook event", func(c *Config) any { return &c.Alerts.WebhookAttempts }},
+	{"alerts.allowed_networks", "ALERTS_ALLOWED_NETWORKS", "comma-separated private CIDRs or IPs webhooks may target", func(c *Config) any { return &c.Alerts.AllowedNetworks }},
+	{"alerts.poll_interval", "ALERTS_POLL_INTERVAL", "interval between price checks for alerts without client traffic (0: off)", func(c *Config) any { return &c.Alerts.PollInterval }},
+	{"tracing.exporter", "TRACING_EXPORTER", "where spans go: none, stdout or otlp", func(c *Config) any { return &c.Tracing.Exporter }},
+	{"tracing.endpoint", "TRACING_ENDPOINT", "OTLP/HTTP collector URL, e.g. http://collector:4318", func(c *Config) any { return &c.Tracing.Endpoint }},
+	{"tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "share of new traces recorded, 0 to 1 (sampled parents are always followed)", func(c *Config) any { return &c.Tracing.SampleRatio }},
+	{"tracing.service_name", "TRACING_SERVICE_NAME", "service.name resource attribute", func(c *Config) any { return &c.Tracing.ServiceName }},
+}
+
+// Load builds the configuration from defaults, the config file (-config flag
+// or CONFIG_FILE), environment variables read through getenv and the flags in
+// args, then validates it. It returns flag.ErrHelp if -h was given.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("bitcoin-prices", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML or JSON config file (env CONFIG_FILE)")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		key := s.key
+		fs.Func(key, fmt.Sprintf("%s (env %s)", s.help, s.env), func(v string) error {
+			flags[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cfg, err
+		}
+		return cfg, fmt.Errorf("flags: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("flags: unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return cfg, err
+		}
+	}
+	var errs []error
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := setValue(s.ptr(&cfg), v); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.key]; ok {
+			if err := setValue(s.ptr(&cfg), v); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", s.key, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Usage writes the available flags and their environment variables to w.
+func Usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: bitcoin-prices [flags]\n\nPrecedence: flags > environment > config file > defaults.\n\n")
+	fmt.Fprintf(w, "  -config string\n\tYAML or JSON config file (env CONFIG_FILE)\n")
+	def := Default()
+	for _, s := range settings {
+		fmt.Fprintf(w, "  -%s\n\t%s (env %s, default %s)\n", s.key, s.help, s.env, formatValue(s.ptr(&def)))
+	}
+}
+
+// loadFile decodes a YAML (.yaml, .yml) or JSON (.json) file over cfg.
+// Unknown keys are errors.
+func loadFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s: unknown format (use .yaml, .yml or .json)", path)
+	}
+	return nil
+}
+
+func setValue(ptr any, v string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = i
+	case *float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+	case *Duration:
+		return p.UnmarshalText([]byte(v))
+	case *RouteLimit:
+		rate, burst, hasBurst := strings.Cut(v, "/")
+		r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil {
+			return fmt.Errorf("invalid rate %q (want RATE[/BURST])", v)
+		}
+		l := RouteLimit{Rate: r}
+		if hasBurst {
+			if l.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil {
+				return fmt.Errorf("invalid burst %q (want RATE[/BURST])", v)
+			}
+		}
+		*p = l
+	case *APIKeys:
+		return p.Set(v)
+	case *Map[float64]:
+		return p.Set(v)
+	case *Map[Duration]:
+		return p.Set(v)
+	case *Map[RouteLimit]:
+		return p.Set(v)
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", ptr)
+	}
+	return nil
+}
+
+func formatValue(ptr any) string {
+	switch p := ptr.(type) {
+	case *string:
+		if *p == "" {
+			return `""`
+		}
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'f', -1, 64)
+	case *Duration:
+		return p.String()
+	case *RouteLimit:
+		return strconv.FormatFloat(p.Rate, 'f', -1, 64) + "/" + strconv.Itoa(p.Burst)
+	case *APIKeys:
+		return p.String()
+	case *Map[float64]:
+		return p.String()
+	case *Map[Duration]:
+		return p.String()
+	case *Map[RouteLimit]:
+		return p.String()
+	case *[]string:
+		if len(*p) == 0 {
+			return `""`
+		}
+		return strings.Join(*p, ",")
+	}
+	return ""
+}
+
+// Validate checks every value and reports all problems at once. It also
+// normalizes the pairs in Guard.PerPair and Pairs.Enabled.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+	check(c.Port > 0 && c.Port <= 65535, "port", "must be between 1 and 65535, got %d", c.Port)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format", "must be text or json, got %q", c.Log.Format)
+	for _, route := range c.Log.AccessSample.keys() {
+		v := c.Log.AccessSample[route]
+		check(strings.HasPrefix(route, "/"), "log.access_sample", "route %q must be a path", route)
+		check(v >= 0 && v <= 1, "log.access_sample", "%s must be between 0 and 1, got %v", route, v)
+	}
+	check(c.Cache.TTL > 0, "cache.ttl", "must be positive, got %s", c.Cache.TTL)
+	check(c.Cache.MaxEntries >= 0, "cache.max_entries", "must not be negative, got %d", c.Cache.MaxEntries)
+	check(c.Cache.StateInterval > 0, "cache.state_interval", "must be positive, got %s", c.Cache.StateInterval)
+	u, err := url.Parse(c.Kraken.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"kraken.base_url", "must be an http(s) URL, got %q", c.Kraken.BaseURL)
+	check(c.Kraken.Timeout > 0, "kraken.timeout", "must be positive, got %s", c.Kraken.Timeout)
+	check(c.Kraken.Retries >= 0, "kraken.retries", "must not be negative, got %d", c.Kraken.Retries)
+	c.validateTimeouts(check)
+	for _, p := range c.Server.TrustedProxies {
+		_, perr := netip.ParsePrefix(p)
+		_, aerr := netip.ParseAddr(p)
+		check(perr == nil || aerr == nil, "server.trusted_proxies", "%q must be a CIDR or an IP", p)
+	}
+	c.validateTLS(check)
+	c.validateAuth(check)
+	c.validateRateLimit(check)
+	check(c.Kraken.BatchSize > 0, "kraken.batch_size", "must be positive, got %d", c.Kraken.BatchSize)
+	check(c.LTP.StaleAfter >= 0, "ltp.stale_after", "must not be negative, got %s", c.LTP.StaleAfter)
+	check(c.LTP.SnapshotRetention > 0, "ltp.snapshot_retention", "must be positive, got %s", c.LTP.SnapshotRetention)
+	check(c.Ready.MaxClockSkew >= 0, "ready.max_clock_skew", "must not be negative, got %s", c.Ready.MaxClockSkew)
+	_, err = decimal.ParseRoundingMode(c.Convert.Rounding)
+	check(err == nil, "convert.rounding", "must be half_even, half_up, down or up, got %q", c.Convert.Rounding)
+	check(c.Guard.MaxDeviation >= 0, "guard.max_deviation", "must not be negative, got %v", c.Guard.MaxDeviation)
+	check(c.Guard.Window > 0, "guard.window", "must be positive, got %s", c.Guard.Window)
+	check(c.Alerts.DeadLetterMaxBytes > 0, "alerts.dead_letter_max_bytes", "must be positive, got %d", c.Alerts.DeadLetterMaxBytes)
+	check(c.Alerts.WebhookAttempts > 0, "alerts.webhook_attempts", "must be positive, got %d", c.Alerts.WebhookAttempts)
+	for _, n := range c.Alerts.AllowedNetworks {
+		_, perr := netip.ParsePrefix(n)
+		_, aerr := netip.ParseAddr(n)
+		check(perr == nil || aerr == nil, "alerts.allowed_networks", "%q must be a CIDR or an IP", n)
+	}
+	check(c.Alerts.PollInterval >= 0, "alerts.poll_interval", "must not be negative, got %s", c.Alerts.PollInterval)
+	c.validateTracing(check)
+
+	if len(c.Guard.PerPair) > 0 {
+		norm := make(Map[float64], len(c.Guard.PerPair))
+		for _, raw := range c.Guard.PerPair.keys() {
+			p, err := pairs.Canonical(raw)
+			check(err == nil, "guard.per_pair", "%v", err)
+			pct := c.Guard.PerPair[raw]
+			check(pct >= 0, "guard.per_pair", "%s must not be negative, got %v", raw, pct)
+			if err == nil {
+				norm[p] = pct
+			}
+		}
+		c.Guard.PerPair = norm
+	}
+	for i, raw := range c.Pairs.Enabled {
+		p, err := pairs.Canonical(raw)
+		check(err == nil, "pairs.enabled", "%v", err)
+		if err == nil {
+			c.Pairs.Enabled[i] = p
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateTimeouts checks the server timeouts. Every handler deadline must
+// exceed the worst case of a Kraken call with all its retries, so a request
+// gets Kraken's answer (or its last error) instead of a bare timeout, and the
+// write timeout must leave room for the longest deadline. Reload checks this
+// again, as kraken.retries changes at runtime and the timeouts do not.
+func (c *Config) validateTimeouts(check func(ok bool, key, format string, args ...any)) {
+	sc := c.Server
+	check(sc.ReadHeaderTimeout > 0, "server.read_header_timeout", "must be positive, got %s", sc.ReadHeaderTimeout)
+	check(sc.ReadTimeout >= 0, "server.read_timeout", "must not be negative, got %s", sc.ReadTimeout)
+	check(sc.IdleTimeout >= 0, "server.idle_timeout", "must not be negative, got %s", sc.IdleTimeout)
+	check(sc.MaxHeaderBytes >= 4096, "server.max_header_bytes", "must be at least 4096, got %d", sc.MaxHeaderBytes)
+
+	budget := kraken.RetryBudget(c.Kraken.Timeout.D(), max(c.Kraken.Retries, 0))
+	check(sc.HandlerTimeout.D() > budget, "server.handler_timeout",
+		"must exceed the Kraken retry budget of %s (kraken.timeout %s, kraken.retries %d), got %s",
+		budget, c.Kraken.Timeout, c.Kraken.Retries, sc.HandlerTimeout)
+	longest := sc.HandlerTimeout
+	for _, route := range sc.RouteTimeouts.keys() {
+		d := sc.RouteTimeouts[route]
+		check(strings.HasPrefix(route, "/"), "server.route_timeouts", "route %q must be a path", route)
+		check(d == 0 || d.D() > budget, "server.route_timeouts",
+			"%s must be 0 (no deadline) or exceed the Kraken retry budget of %s, got %s", route, budget, d)
+		longest = max(longest, d)
+	}
+	check(sc.WriteTimeout == 0 || sc.WriteTimeout > longest, "server.write_timeout",
+		"must be 0 or exceed the longest handler deadline of %s, got %s", longest, sc.WriteTimeout)
+}
+
+func (c *Config) validateTLS(check func(ok bool, key, format string, args ...any)) {
+	t := c.TLS
+	check((t.CertFile == "") == (t.KeyFile == ""), "tls", "cert_file and key_file must be set together")
+	check(t.ClientCAFile == "" || t.Enabled(), "tls.client_ca_file", "requires tls.cert_file and tls.key_file")
+	check(t.ClientAuth == "require" || t.ClientAuth == "verify_if_given", "tls.client_auth",
+		"must be require or verify_if_given, got %q", t.ClientAuth)
+	check(t.Port >= 0 && t.Port <= 65535, "tls.port", "must be between 0 and 65535, got %d", t.Port)
+	check(t.Port == 0 || t.Port != c.Port, "tls.port", "must differ from port (use 0 to serve HTTPS on port)")
+	check(t.ReloadInterval > 0, "tls.reload_interval", "must be positive, got %s", t.ReloadInterval)
+	for _, f := range []struct{ key, path string }{
+		{"tls.cert_file", t.CertFile}, {"tls.key_file", t.KeyFile}, {"tls.client_ca_file", t.ClientCAFile},
+	} {
+		if f.path != "" {
+			_, err := os.Stat(f.path)
+			check(err == nil, f.key, "%v", err)
+		}
+	}
+}
+
+func (c *Config) validateAuth(check func(ok bool, key, format string, args ...any)) {
+	ids := make(map[string]bool)
+	for i, k := range c.Auth.Keys {
+		name := fmt.Sprintf("auth.keys[%d]", i)
+		check(k.ID != "", name, "id is required")
+		check(!ids[k.ID], name, "duplicate id %q", k.ID)
+		ids[k.ID] = true
+		_, err := k.Digest()
+		check(err == nil, name, "%v", err)
+		for _, scope := range k.Scopes {
+			check(strings.HasPrefix(scope, "/"), name, "scope %q must be a route path", scope)
+		}
+		check(k.Quota >= 0, name, "quota must not be negative, got %d", k.Quota)
+		check(k.Quota == 0 || k.QuotaWindow > 0, name, "quota_window must be positive with a quota, got %s", k.QuotaWindow)
+	}
+}
+
+func (c *Config) validateRateLimit(check func(ok bool, key, format string, args ...any)) {
+	rl := c.RateLimit
+	check(rl.Rate >= 0, "ratelimit.rate", "must not be negative, got %v", rl.Rate)
+	check(rl.Burst >= 0, "ratelimit.burst", "must not be negative, got %d", rl.Burst)
+	check(rl.MaxClients > 0, "ratelimit.max_clients", "must be positive, got %d", rl.MaxClients)
+	for _, route := range rl.Routes.keys() {
+		l := rl.Routes[route]
+		check(strings.HasPrefix(route, "/"), "ratelimit.routes", "route %q must be a path", route)
+		check(l.Rate >= 0 && l.Burst >= 0, "ratelimit.routes", "%s rate and burst must not be negative", route)
+	}
+}
+
+func (c *Config) validateTracing(check func(ok bool, key, format string, args ...any)) {
+	t := c.Tracing
+	check(t.Exporter == "none" || t.Exporter == "stdout" || t.Exporter == "otlp", "tracing.exporter",
+		"must be none, stdout or otlp, got %q", t.Exporter)
+	if t.Exporter == "otlp" {
+		u, err := url.Parse(t.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.endpoint", "must be an http(s) URL with the otlp exporter, got %q", t.Endpoint)
+	}
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1, got %v", t.SampleRatio)
+	check(t.ServiceName != "", "tracing.service_name", "must not be empty")
+}
+
+// Duration is a time.Duration that is written as a Go duration string
+// ("10s", "1m30s"). A bare integer is read as seconds.
+type Duration time.Duration
+
+// D returns d as a time.Duration.
+func (d Duration) D() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*d = Duration(time.Duration(n) * time.Second)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (use e.g. 10s, 5m or 1h30m)", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		s = string(b) // bare number of seconds
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: duration must be a scalar", n.Line)
+	}
+	return d.UnmarshalText([]byte(n.Value))
+}
+
+// Map maps request paths or pairs to values of one setting type. In
+// environment variables and flags it is written as "KEY=VALUE,KEY=VALUE",
+// each value as for a setting of type V (e.g. "/api/v1/ohlc=15s" for
+// Durations, "/api/v1/ohlc=1/5" for RouteLimits).
+type Map[V any] map[string]V
+
+func (m *Map[V]) Set(v string) error {
+	out := make(Map[V])
+	for _, item := range strings.Split(v, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		k, raw, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid entry %q (want KEY=VALUE)", item)
+		}
+		var val V
+		if err := setValue(&val, raw); err != nil {
+			return fmt.Errorf("invalid entry %q: %w", item, err)
+		}
+		out[strings.TrimSpace(k)] = val
+	}
+	*m = out
+	return nil
+}
+
+func (m Map[V]) String() string {
+	parts := make([]string, 0, len(m))
+	for _, k := range m.keys() {
+		v := m[k]
+		parts = append(parts, k+"="+formatValue(&v))
+	}
+	if len(parts) == 0 {
+		return `""`
+	}
+	return strings.Join(parts, ",")
+}
+
+// keys returns the keys of m in sorted order.
+func (m Map[V]) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Cache.TTL.D() != 10*time.Second || cfg.Addr() != ":8080" || cfg.Kraken.BaseURL != "https://api.kraken.com" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "cfg.yaml", `
+cache:
+  ttl: 30s
+  max_entries: 50
+server:
+  route_timeouts:
+    /api/v1/ohlc: 50s
+auth:
+  keys:
+    - id: yaml
+      hash: sha256:0000000000000000000000000000000000000000000000000000000000000000
+      scopes: [/api/v1/ltp]
+kraken:
+  retries: 5
+guard:
+  per_pair:
+    btc/usd: 10
+`)
+	cfg, err := Load(
+		[]string{"-config", path, "-kraken.retries=7", "-server.handler_timeout=45s", "-server.write_timeout=0"},
+		env(map[string]string{"CACHE_TTL": "45s", "KRAKEN_RETRIES": "6"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Cache.TTL.D() != 45*time.Second {
+		t.Fatalf("env should override file, got ttl %s", cfg.Cache.TTL)
+	}
+	if cfg.Cache.MaxEntries != 50 {
+		t.Fatalf("file should override default, got %d", cfg.Cache.MaxEntries)
+	}
+	if cfg.Kraken.Retries != 7 {
+		t.Fatalf("flag should override env, got %d", cfg.Kraken.Retries)
+	}
+	if cfg.Server.RouteTimeouts["/api/v1/ohlc"].D() != 50*time.Second || cfg.Auth.Keys[0].Scopes[0] != "/api/v1/ltp" {
+		t.Fatalf("expected structured file settings, got %+v %+v", cfg.Server, cfg.Auth)
+	}
+	if cfg.Guard.PerPair["BTC/USD"] != 10 {
+		t.Fatalf("expected normalized per-pair override, got %v", cfg.Guard.PerPair)
+	}
+}
+
+func TestLoad_JSONFileFromEnv(t *testing.T) {
+	path := writeFile(t, "cfg.json", `{"port": 9090, "ltp": {"stale_after": "2m"}, "guard": {"window": 30, "per_pair": {"BTC/USD": 5}},
+		"server": {"route_timeouts": {"/api/v1/ohlc": "9s"}}, "auth": {"keys": [{"id": "a", "hash": "sha256:`+strings.Repeat("0", 64)+`"}]},
+		"ratelimit": {"routes": {"/api/v1/ohlc": {"rate": 1, "burst": 5}}}}`)
+	cfg, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Port != 9090 || cfg.LTP.StaleAfter.D() != 2*time.Minute || cfg.Guard.Window.D() != 30*time.Second ||
+		cfg.Guard.PerPair["BTC/USD"] != 5 || cfg.Server.RouteTimeouts["/api/v1/ohlc"].D() != 9*time.Second || cfg.Auth.Keys[0].ID != "a" ||
+		cfg.RateLimit.Routes["/api/v1/ohlc"].Burst != 5 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoad_Durations(t *testing.T) {
+	for raw, want := range map[string]time.Duration{"10": 10 * time.Second, "10s": 10 * time.Second, "1m30s": 90 * time.Second, "250ms": 250 * time.Millisecond} {
+		cfg, err := Load(nil, env(map[string]string{"CACHE_TTL": raw}))
+		if err != nil || cfg.Cache.TTL.D() != want {
+			t.Fatalf("CACHE_TTL=%s: got %s err=%v, want %s", raw, cfg.Cache.TTL, err, want)
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want []string
+	}{
+		{name: "bad duration", env: map[string]string{"CACHE_TTL": "ten"}, want: []string{"env CACHE_TTL", `invalid duration "ten"`}},
+		{name: "bad int", args: []string{"-kraken.retries=x"}, want: []string{"flag -kraken.retries", `invalid integer "x"`}},
+		{name: "all problems reported", env: map[string]string{"KRAKEN_BATCH_SIZE": "0", "CONVERT_ROUNDING": "nearest", "PORT": "70000"},
+			want: []string{"kraken.batch_size: must be positive", `convert.rounding: must be half_even`, "port: must be between"}},
+		{name: "bad pair", env: map[string]string{"PRICE_MAX_DEVIATION_PAIRS": "BTC/USD=10,DOGE/USD=5"}, want: []string{"guard.per_pair"}},
+		{name: "bad url", env: map[string]string{"KRAKEN_BASE_URL": "api.kraken.com"}, want: []string{"kraken.base_url"}},
+		{name: "bad trusted proxy", env: map[string]string{"SERVER_TRUSTED_PROXIES": "10.0.0.0/8, proxy.local"}, want: []string{`server.trusted_proxies: "proxy.local"`}},
+		{name: "bad tracing", env: map[string]string{"TRACING_EXPORTER": "otlp", "TRACING_SAMPLE_RATIO": "2"},
+			want: []string{"tracing.endpoint: must be an http(s) URL", "tracing.sample_ratio: must be between 0 and 1"}},
+		{name: "bad log settings", env: map[string]string{"LOG_FORMAT": "xml", "LOG_ACCESS_SAMPLE": "/api/health=0,/api/v1/ltp=2"},
+			want: []string{`log.format: must be text or json, got "xml"`, "log.access_sample: /api/v1/ltp must be between 0 and 1"}},
+		{name: "unknown file key", file: "cache:\n  tll: 10s\n", want: []string{"field tll not found"}},
+		{name: "unknown flag", args: []string{"-cache.tll=10s"}, want: []string{"flag provided but not defined"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "cfg.yaml", tt.file))
+			}
+			_, err := Load(args, env(tt.env))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Fatalf("expected %q in error, got: %v", w, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_Timeouts(t *testing.T) {
+	cfg, err := Load([]string{"-server.route_timeouts=/api/v1/ohlc=15s,/api/v1/stream=0", "-server.write_timeout=20s"}, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.Server.RouteTimeouts["/api/v1/ohlc"].D() != 15*time.Second || cfg.Server.RouteTimeouts["/api/v1/stream"] != 0 {
+		t.Fatalf("unexpected route timeouts: %v", cfg.Server.RouteTimeouts)
+	}
+
+	tests := map[string][]string{
+		// 3 attempts of 2s plus 600ms of backoff
+		"server.handler_timeout: must exceed the Kraken retry budget of 6.6s": {"-server.handler_timeout=6s"},
+		"/api/v1/ohlc must be 0 (no deadline) or exceed":                      {"-server.route_timeouts=/api/v1/ohlc=5s"},
+		"server.write_timeout: must be 0 or exceed the longest handler deadline of 15s": {
+			"-server.route_timeouts=/api/v1/ohlc=15s"},
+		"server.max_header_bytes": {"-server.max_header_bytes=100"},
+	}
+	for want, args := range tests {
+		if _, err := Load(args, env(nil)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestLoad_TLS(t *testing.T) {
+	cert, key := writeFile(t, "tls.crt", "cert"), writeFile(t, "tls.key", "key")
+	cfg, err := Load([]string{"-tls.cert_file", cert, "-tls.key_file", key, "-tls.port=8443"}, env(nil))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !cfg.TLS.Enabled() || cfg.TLSAddr() != ":8443" || cfg.Addr() != ":8080" {
+		t.Fatalf("expected separate listeners, got %+v", cfg.TLS)
+	}
+
+	tests := map[string][]string{
+		"cert_file and key_file must be set together": {"-tls.cert_file", cert},
+		"tls.port: must differ from port":             {"-tls.cert_file", cert, "-tls.key_file", key, "-tls.port=8080"},
+		"tls.client_ca_file: requires":                {"-tls.client_ca_file", cert},
+		"tls.client_auth: must be require":            {"-tls.client_auth=optional"},
+		"tls.key_file: stat":                          {"-tls.cert_file", cert, "-tls.key_file", key + ".missing"},
+	}
+	for want, args := range tests {
+		if _, err := Load(args, env(nil)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestLoad_AuthKeys(t *testing.T) {
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	cfg, err := Load(nil, env(map[string]string{
+		"AUTH_KEYS": `[{"id":"dash","hash":"` + hash + `","scopes":["/api/v1/ltp"],"quota":100,"quota_window":"1h"}]`,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	k := cfg.Auth.Keys[0]
+	if d, err := k.Digest(); err != nil || d[0] != 0xab || k.QuotaWindow.D() != time.Hour {
+		t.Fatalf("unexpected key: %+v err=%v", k, err)
+	}
+
+	tests := map[string]string{
+		"invalid key list":                        `{"id":"x"}`,
+		"hash must be sha256:<64 hex digits>":     `[{"id":"x","hash":"plaintext"}]`,
+		`duplicate id "x"`:                        `[{"id":"x","hash":"` + hash + `"},{"id":"x","hash":"` + hash + `"}]`,
+		"quota_window must be positive":           `[{"id":"x","hash":"` + hash + `","quota":5}]`,
+		`scope "api/v1/ltp" must be a route path`: `[{"id":"x","hash":"` + hash + `","scopes":["api/v1/ltp"]}]`,
+	}
+	for want, keys := range tests {
+		if _, err := Load(nil, env(map[string]string{"AUTH_KEYS": keys})); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", keys, want, err)
+		}
+	}
+}
+
+func TestLoad_RateLimit(t *testing.T) {
+	cfg, err := Load([]string{"-ratelimit.routes=/api/v1/ohlc=1/5, /api/v1/ltp=0"}, env(map[string]string{"RATELIMIT_RATE": "2.5"}))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if cfg.RateLimit.Rate != 2.5 || cfg.RateLimit.MaxClients != 10000 {
+		t.Fatalf("unexpected rate limit: %+v", cfg.RateLimit)
+	}
+	if l := cfg.RateLimit.Routes["/api/v1/ohlc"]; l.Rate != 1 || l.Burst != 5 {
+		t.Fatalf("unexpected route limit: %+v", cfg.RateLimit.Routes)
+	}
+	if l, ok := cfg.RateLimit.Routes["/api/v1/ltp"]; !ok || l.Rate != 0 {
+		t.Fatalf("expected unlimited ltp route, got %+v", cfg.RateLimit.Routes)
+	}
+
+	for _, args := range [][]string{
+		{"-ratelimit.rate=-1"},
+		{"-ratelimit.max_clients=0"},
+		{"-ratelimit.routes=api/v1/ltp=1"},
+		{"-ratelimit.routes=/api/v1/ltp=1/x"},
+	} {
+		if _, err := Load(args, env(nil)); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	if _, err := Load([]string{"-h"}, env(nil)); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+	var b strings.Builder
+	Usage(&b)
+	if !strings.Contains(b.String(), "-cache.ttl") || !strings.Contains(b.String(), "CACHE_TTL") {
+		t.Fatalf("unexpected usage: %s", b.String())
+	}
+}
+
+func TestMap_Set(t *testing.T) {
+	var m Map[float64]
+	if err := m.Set("btc/usd=10, BTC/CHF=25"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(m) != 2 || m["btc/usd"] != 10 || m["BTC/CHF"] != 25 {
+		t.Fatalf("unexpected result: %v", m)
+	}
+	if err := m.Set("BTC/EUR=x"); err == nil {
+		t.Fatal("expected error for invalid percentage")
+	}
+
+	var limits Map[RouteLimit]
+	if err := limits.Set("/api/v1/ohlc=1/5,/api/v1/ltp=2.5"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got := limits.String(); got != "/api/v1/ltp=2.5/0,/api/v1/ohlc=1/5" {
+		t.Fatalf("unexpected limits %s", got)
+	}
+	if err := limits.Set("/api/v1/ohlc=1/x"); err == nil {
+		t.Fatal("expected error for invalid burst")
+	}
+
+	var timeouts Map[Duration]
+	if err := timeouts.Set("/api/v1/ohlc=15s,/api/v1/stream=0"); err != nil || timeouts.String() != "/api/v1/ohlc=15s,/api/v1/stream=0s" {
+		t.Fatalf("unexpected timeouts %s err=%v", timeouts.String(), err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	cur := Default()
+	cur.Redis.Password = "old"
+	next := Default()
+	next.Cache.TTL = Duration(30 * time.Second)
+	next.Pairs.Enabled = []string{"BTC/USD"}
+	next.Port = 9090
+	next.Redis.Password = "new"
+
+	got, changes, err := Reload(cur, next)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got.Cache.TTL.D() != 30*time.Second || len(got.Pairs.Enabled) != 1 {
+		t.Fatalf("expected reloadable settings applied, got %+v", got)
+	}
+	if got.Port != 8080 || got.Redis.Password != "old" {
+		t.Fatalf("expected restart-only settings kept, got %+v", got)
+	}
+	want := map[string]bool{"port": false, "pairs.enabled": true, "cache.ttl": true, "redis.password": false}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	for _, c := range changes {
+		if r, ok := want[c.Key]; !ok || r != c.Reloadable {
+			t.Fatalf("unexpected change: %+v", c)
+		}
+		if c.Key == "redis.password" && (c.Old != "(redacted)" || c.New != "(redacted)") {
+			t.Fatalf("expected secret redacted, got %+v", c)
+		}
+	}
+}
+
+func TestReload_RejectsInvalidMerge(t *testing.T) {
+	cur := Default()
+	cur.Kraken.Timeout = Duration(2 * time.Second)
+	cur.Kraken.Retries = 1
+	cur.Server.HandlerTimeout = Duration(8 * time.Second)
+	// valid on its own (budget 5.4s), but kraken.timeout needs a restart:
+	// 3 retries of 2s each (9.4s) do not fit the handler timeout
+	next := cur
+	next.Kraken.Timeout = Duration(time.Second)
+	next.Kraken.Retries = 3
+	if err := next.Validate(); err != nil {
+		t.Fatalf("expected next valid, got %v", err)
+	}
+
+	got, _, err := Reload(cur, next)
+	if err == nil || !strings.Contains(err.Error(), "server.handler_timeout") {
+		t.Fatalf("expected the merged config rejected, got %v", err)
+	}
+	if got.Kraken.Retries != 1 {
+		t.Fatalf("expected the current config returned, got retries %d", got.Kraken.Retries)
+	}
+}
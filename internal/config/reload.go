@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// reloadable lists the settings a running server applies on reload; every
+// other change needs a restart.
+var reloadable = map[string]bool{
+	"log.level":        true,
+	"pairs.enabled":    true,
+	"cache.ttl":        true,
+	"kraken.retries":   true,
+	"auth.keys":        true,
+	"ratelimit.rate":   true,
+	"ratelimit.burst":  true,
+	"ratelimit.routes": true,
+}
+
+// secrets are never shown in a Change.
+var secrets = map[string]bool{
+	"admin.token":    true,
+	"redis.password": true,
+	"auth.keys":      true, // only hashes, but no need to log them
+}
+
+// Change is a setting that differs between two configurations.
+type Change struct {
+	Key        string `json:"key"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	Reloadable bool   `json:"reloadable"`
+}
+
+// Reload returns cur with every reloadable setting taken from next, along with
+// all settings that differ. Changes that need a restart are reported but not
+// applied. Both configurations must be valid; the result is validated too, as
+// restart-only settings kept from cur can conflict with reloaded ones (a
+// handler timeout too short for more Kraken retries), and an error is
+// returned if it is not.
+func Reload(cur, next Config) (Config, []Change, error) {
+	out := cur
+	var changes []Change
+	for _, s := range settings {
+		oldV, newV := formatValue(s.ptr(&cur)), formatValue(s.ptr(&next))
+		if oldV == newV {
+			continue
+		}
+		if secrets[s.key] {
+			oldV, newV = "(redacted)", "(redacted)"
+		}
+		changes = append(changes, Change{Key: s.key, Old: oldV, New: newV, Reloadable: reloadable[s.key]})
+		if reloadable[s.key] {
+			reflect.ValueOf(s.ptr(&out)).Elem().Set(reflect.ValueOf(s.ptr(&next)).Elem())
+		}
+	}
+	if err := out.Validate(); err != nil {
+		return cur, changes, fmt.Errorf("settings that need a restart conflict with the new ones: %w", err)
+	}
+	return out, changes, nil
+}
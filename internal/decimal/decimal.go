@@ -0,0 +1,91 @@
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how Round resolves digits beyond the requested scale.
+type RoundingMode string
+
+const (
+	HalfEven RoundingMode = "half_even" // banker's rounding
+	HalfUp   RoundingMode = "half_up"
+	Down     RoundingMode = "down" // towards zero
+	Up       RoundingMode = "up"   // away from zero
+)
+
+// ParseRoundingMode validates a rounding mode name.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch m := RoundingMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case HalfEven, HalfUp, Down, Up:
+		return m, nil
+	}
+	return "", fmt.Errorf("unsupported rounding mode: %s", s)
+}
+
+var decimalRe = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// Parse parses a plain decimal string like "0.35" exactly.
+// Fractions and exponents are rejected.
+func Parse(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if !decimalRe.MatchString(s) {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	return r, nil
+}
+
+// FromFloat converts f via its shortest decimal representation, so a price
+// Kraken sent as "52000.12" becomes exactly 52000.12 rather than the nearest binary float.
+func FromFloat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// Round formats r with exactly scale decimal places using mode.
+func Round(r *big.Rat, scale int, mode RoundingMode) string {
+	if scale < 0 {
+		scale = 0
+	}
+	neg := r.Sign() < 0
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(new(big.Rat).Abs(r), new(big.Rat).SetInt(pow))
+
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		twice := new(big.Int).Lsh(rem, 1)
+		cmp := twice.Cmp(scaled.Denom())
+		switch mode {
+		case Up:
+			q.Add(q, big.NewInt(1))
+		case HalfUp:
+			if cmp >= 0 {
+				q.Add(q, big.NewInt(1))
+			}
+		case HalfEven:
+			if cmp > 0 || (cmp == 0 && q.Bit(0) == 1) {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+
+	digits := q.String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if neg && q.Sign() != 0 {
+		digits = "-" + digits
+	}
+	return digits
+}
@@ -0,0 +1,58 @@
+package decimal
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	if r, err := Parse(" 0.35 "); err != nil || r.RatString() != "7/20" {
+		t.Fatalf("expected 7/20, got %v err=%v", r, err)
+	}
+	for _, bad := range []string{"", "1/3", "1e3", "0x10", "1.", ".5", "abc"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestFromFloat_Exact(t *testing.T) {
+	if got := FromFloat(52000.12).RatString(); got != "1300003/25" {
+		t.Fatalf("expected exact 52000.12, got %s", got)
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		in    string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{"2.345", 2, HalfEven, "2.34"},
+		{"2.355", 2, HalfEven, "2.36"},
+		{"2.345", 2, HalfUp, "2.35"},
+		{"2.349", 2, Down, "2.34"},
+		{"2.341", 2, Up, "2.35"},
+		{"0.000123", 4, HalfUp, "0.0001"},
+		{"-1.005", 2, HalfUp, "-1.01"},
+		{"17150.042", 0, HalfEven, "17150"},
+		{"5", 3, HalfEven, "5.000"},
+		{"-0.001", 2, Down, "0.00"},
+	}
+	for _, tc := range cases {
+		r, err := Parse(tc.in)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tc.in, err)
+		}
+		if got := Round(r, tc.scale, tc.mode); got != tc.want {
+			t.Fatalf("Round(%s, %d, %s) = %s, want %s", tc.in, tc.scale, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	if m, err := ParseRoundingMode("HALF_UP"); err != nil || m != HalfUp {
+		t.Fatalf("expected half_up, got %q err=%v", m, err)
+	}
+	if _, err := ParseRoundingMode("ceil"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
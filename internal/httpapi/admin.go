@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"bitcoin-prices/internal/config"
+)
+
+// ReloadFunc reloads the configuration and reports what changed.
+type ReloadFunc func() ([]config.Change, error)
+
+// reloadHandler serves POST /api/admin/reload. Requests must carry
+// "Authorization: Bearer <token>".
+func reloadHandler(logger *slog.Logger, token string, reload ReloadFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validToken(r, token) {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		changes, err := reload()
+		if err != nil {
+			// the previous configuration stays active
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+			return
+		}
+		applied, restart := []config.Change{}, []config.Change{}
+		for _, c := range changes {
+			if c.Reloadable {
+				applied = append(applied, c)
+			} else {
+				restart = append(restart, c)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"applied": applied, "restart_required": restart})
+	}
+}
+
+// logLevelHandler serves /api/admin/log-level: GET returns the current log
+// level, PUT {"level": "debug"} changes it until the next restart, or until
+// a reload changes log.level. Requests must carry "Authorization: Bearer
+// <token>".
+func logLevelHandler(logger *slog.Logger, token string, level *slog.LevelVar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r, token) {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req struct {
+				Level string `json:"level"`
+			}
+			var l slog.Level
+			if err := json.NewDecoder(io.LimitReader(r.Body, 1<<10)).Decode(&req); err != nil || l.UnmarshalText([]byte(req.Level)) != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "level must be debug, info, warn or error"})
+				return
+			}
+			if old := level.Level(); old != l {
+				level.Set(l)
+				logger.WarnContext(r.Context(), "log level changed", "old", old, "new", l)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"level": strings.ToLower(level.Level().String())})
+	}
+}
+
+func validToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
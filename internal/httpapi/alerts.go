@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"bitcoin-prices/internal/alerts"
+)
+
+// alertsHandler serves GET (list) and POST (create) on /api/v1/alerts.
+func alertsHandler(logger *slog.Logger, m *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]any{"alerts": m.List()})
+		case http.MethodPost:
+			a, ok := decodeAlert(w, r)
+			if !ok {
+				return
+			}
+			created, err := m.Create(a)
+			if err != nil {
+				writeAlertError(w, r, logger, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, created)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// alertHandler serves GET, PUT and DELETE on /api/v1/alerts/{id}.
+func alertHandler(logger *slog.Logger, m *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		switch r.Method {
+		case http.MethodGet:
+			a, err := m.Get(id)
+			if err != nil {
+				writeAlertError(w, r, logger, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, a)
+		case http.MethodPut:
+			a, ok := decodeAlert(w, r)
+			if !ok {
+				return
+			}
+			updated, err := m.Update(id, a)
+			if err != nil {
+				writeAlertError(w, r, logger, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, updated)
+		case http.MethodDelete:
+			if err := m.Delete(id); err != nil {
+				writeAlertError(w, r, logger, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func decodeAlert(w http.ResponseWriter, r *http.Request) (alerts.Alert, bool) {
+	var a alerts.Alert
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&a); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid request body: " + err.Error()})
+		return a, false
+	}
+	return a, true
+}
+
+// writeAlertError maps not found to 404, persistence failures to 500 and
+// everything else (validation) to 400.
+func writeAlertError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, alerts.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, alerts.ErrPersist):
+		logger.ErrorContext(r.Context(), "alert store failed", "err", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "failed to save alert"})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bitcoin-prices/internal/clock"
+)
+
+// APIKey is a client key known by the SHA-256 digest of its secret.
+type APIKey struct {
+	ID     string
+	Digest [sha256.Size]byte
+	// Scopes are the routes the key may call, as mounted; a trailing "*"
+	// matches a prefix. Empty allows every route.
+	Scopes      []string
+	Quota       int // requests per QuotaWindow; 0 is unlimited
+	QuotaWindow time.Duration
+}
+
+func (k *APIKey) allows(route string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if prefix, ok := strings.CutSuffix(s, "*"); (ok && strings.HasPrefix(route, prefix)) || s == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator checks API keys and counts their requests against quotas in
+// fixed windows. Without keys authentication is off and every request is let
+// through. Keys can be replaced at runtime; usage is kept by key ID.
+type Authenticator struct {
+	keys atomic.Pointer[map[[sha256.Size]byte]*APIKey]
+
+	mu    sync.Mutex
+	usage map[string]*quotaWindow
+	clock clock.Clock
+}
+
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// NewAuthenticator returns an Authenticator accepting keys, timing quota
+// windows with clk (nil: clock.Real).
+func NewAuthenticator(keys []APIKey, clk clock.Clock) *Authenticator {
+	if clk == nil {
+		clk = clock.Real
+	}
+	a := &Authenticator{usage: make(map[string]*quotaWindow), clock: clk}
+	a.SetKeys(keys)
+	return a
+}
+
+// SetKeys replaces the accepted keys. Requests already authenticated are not
+// affected.
+func (a *Authenticator) SetKeys(keys []APIKey) {
+	m := make(map[[sha256.Size]byte]*APIKey, len(keys))
+	for i := range keys {
+		k := keys[i]
+		m[k.Digest] = &k
+	}
+	a.keys.Store(&m)
+}
+
+// lookup returns the key for the secret presented in r, if any.
+func (a *Authenticator) lookup(r *http.Request) (*APIKey, bool) {
+	secret := r.Header.Get("X-API-Key")
+	if secret == "" {
+		var ok bool
+		if secret, ok = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !ok {
+			return nil, false
+		}
+	}
+	k, ok := (*a.keys.Load())[sha256.Sum256([]byte(secret))]
+	return k, ok
+}
+
+// allow counts a request by k and reports whether it is within quota, and
+// otherwise how long until the window resets.
+func (a *Authenticator) allow(k *APIKey) (bool, time.Duration) {
+	if k.Quota <= 0 {
+		return true, 0
+	}
+	now := a.clock.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w := a.usage[k.ID]
+	if w == nil || !now.Before(w.start.Add(k.QuotaWindow)) {
+		w = &quotaWindow{start: now}
+		a.usage[k.ID] = w
+	}
+	if w.count >= k.Quota {
+		return false, w.start.Add(k.QuotaWindow).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// withAuth requires a valid API key with a scope covering route, records the
+// key ID for logging and enforces the key's quota.
+func withAuth(a *Authenticator, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(*a.keys.Load()) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		k, ok := a.lookup(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bitcoin-prices"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "missing or invalid API key"})
+			return
+		}
+		r = withKeyID(r, k.ID)
+		if !k.allows(route) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"error": fmt.Sprintf("API key not allowed to access %s", route)})
+			return
+		}
+		if ok, wait := a.allow(k); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(wait.Round(time.Second)/time.Second), 1)))
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": "API key quota exceeded"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
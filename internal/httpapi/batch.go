@@ -0,0 +1,41 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"bitcoin-prices/internal/service"
+)
+
+// ltpBatchHandler serves POST /api/v1/ltp:batch. Results are reported per pair,
+// so the response is 200 even if some pairs are invalid or failed to fetch.
+func ltpBatchHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		items, err := service.ParseBatchRequest(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		ctx := r.Context()
+
+		// looked up alongside the prices rather than after them
+		lastTrades := make(chan map[string]time.Time, 1)
+		go func() { lastTrades <- svc.LastTradeTimes(ctx, service.TradeTimePairs(items)) }()
+		results := svc.GetLTPBatch(ctx, items)
+		failed := 0
+		for _, res := range results {
+			if res.Err != nil {
+				failed++
+			}
+		}
+		if failed > 0 {
+			logger.WarnContext(ctx, "ltp batch partially failed", "pairs", len(results), "failed", failed)
+		}
+		writeJSON(w, http.StatusOK, svc.BuildBatchResponse(results, <-lastTrades))
+	}
+}
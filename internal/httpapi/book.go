@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/service"
+)
+
+// orderBookHandler serves GET /api/v1/orderbook?pair=&depth=.
+// depth is the number of levels per side (default 10, max kraken.MaxDepth).
+func orderBookHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		pair, err := service.ParsePairQuery(q.Get("pair"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		depth := 10
+		if v := q.Get("depth"); v != "" {
+			depth, err = strconv.Atoi(v)
+			if err != nil || depth < 1 || depth > kraken.MaxDepth {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid depth: " + v})
+				return
+			}
+		}
+		ctx := r.Context()
+
+		ob, err := svc.GetOrderBook(ctx, pair, depth)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch order book")
+			logger.ErrorContext(ctx, "orderbook fetch failed", "err", err, "pair", pair, "depth", depth)
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildOrderBookResponse(pair, ob))
+	}
+}
+
+// spreadHandler serves GET /api/v1/spread?pair=.
+func spreadHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		pair, err := service.ParsePairQuery(r.URL.Query().Get("pair"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		ctx := r.Context()
+
+		sp, err := svc.GetSpread(ctx, pair)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch spread")
+			logger.ErrorContext(ctx, "spread fetch failed", "err", err, "pair", pair)
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildSpreadResponse(pair, sp))
+	}
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the reverse proxies whose forwarding headers are
+// believed. A request from anywhere else is attributed to its peer address,
+// whatever headers it sends.
+type TrustedProxies struct {
+	nets []netip.Prefix
+}
+
+// ParseTrustedProxies parses CIDRs ("10.0.0.0/8") and single addresses.
+func ParseTrustedProxies(cidrs []string) (*TrustedProxies, error) {
+	p := &TrustedProxies{}
+	for _, s := range cidrs {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q (want a CIDR or an IP)", s)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.nets = append(p.nets, prefix.Masked())
+	}
+	return p, nil
+}
+
+func (p *TrustedProxies) trusts(addr netip.Addr) bool {
+	if p == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, n := range p.nets {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that sent r. Forwarding headers
+// count only when the peer is a trusted proxy; they are then read in order of
+// preference Forwarded (RFC 7239), X-Forwarded-For and X-Real-IP. The address
+// chain is walked from the right, skipping trusted proxies, so the client is
+// the last hop no trusted proxy vouches for; anything a client prepends
+// itself is ignored.
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	peer, ok := parseNode(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !p.trusts(peer) {
+		return peer.String()
+	}
+	var chain []string
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		chain = forwardedFor(fwd)
+	} else if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		for _, line := range xff {
+			chain = append(chain, strings.Split(line, ",")...)
+		}
+	} else if real := r.Header.Get("X-Real-IP"); real != "" {
+		chain = []string{real}
+	}
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop, ok := parseNode(chain[i])
+		if !ok {
+			// obfuscated or garbled: nothing left of it can be trusted
+			break
+		}
+		client = hop
+		if !p.trusts(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor returns the for= nodes of Forwarded header lines, in order.
+func forwardedFor(lines []string) []string {
+	var nodes []string
+	for _, line := range lines {
+		for _, elem := range strings.Split(line, ",") {
+			node := "unknown" // an element without for= breaks the chain
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(k, "for") {
+					node = v
+				}
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// parseNode parses an address as found in RemoteAddr and forwarding
+// headers: optionally quoted, with an optional port, IPv6 in brackets when a
+// port is given.
+func parseNode(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// withClientIP resolves the client address once per request for the request
+// log and rate limiting; see clientIP.
+func withClientIP(p *TrustedProxies, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, withRequestIP(r, p.ClientIP(r)))
+	})
+}
+
+// clientIP returns the client address resolved by withClientIP, or the peer
+// address if the request did not pass through it.
+func clientIP(r *http.Request) string {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok && info.ip != "" {
+		return info.ip
+	}
+	return (*TrustedProxies)(nil).ClientIP(r)
+}
@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+
+	"bitcoin-prices/internal/service"
+)
+
+// convertHandler serves GET /api/v1/convert?from=&to=&amount=[&precision=&rounding=].
+func convertHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		req, err := service.ParseConvertQuery(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		ctx := r.Context()
+
+		conv, err := svc.Convert(ctx, req)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch prices")
+			logger.ErrorContext(ctx, "convert failed", "err", err, "pair", req.Pair)
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildConvertResponse(conv))
+	}
+}
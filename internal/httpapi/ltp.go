@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitcoin-prices/internal/service"
+)
+
+// ltpHandler serves GET /api/v1/ltp. With consistent=true all pairs come from
+// one Kraken call and the response carries a snapshot ID; snapshot=<id>
+// returns a previously taken snapshot.
+func ltpHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		if id := q.Get("snapshot"); id != "" {
+			if q.Has("pairs") || q.Has("consistent") {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "snapshot cannot be combined with pairs or consistent"})
+				return
+			}
+			snap, err := svc.GetSnapshot(id)
+			if err != nil {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, service.BuildSnapshotResponse(snap))
+			return
+		}
+		ps, err := service.ParsePairsQuery(q.Get("pairs"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		consistent := false
+		if raw := q.Get("consistent"); raw != "" {
+			if consistent, err = strconv.ParseBool(raw); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "consistent must be true or false"})
+				return
+			}
+		}
+		ctx := r.Context()
+
+		if consistent {
+			snap, err := svc.TakeSnapshot(ctx, ps)
+			if err != nil {
+				writeUpstreamError(w, err, "failed to fetch prices")
+				logger.ErrorContext(ctx, "ltp snapshot failed", "err", err, "pairs", service.JoinPairs(ps))
+				return
+			}
+			writeJSON(w, http.StatusOK, service.BuildSnapshotResponse(snap))
+			return
+		}
+
+		// looked up alongside the prices rather than after them
+		lastTrades := make(chan map[string]time.Time, 1)
+		go func() { lastTrades <- svc.LastTradeTimes(ctx, ps) }()
+		prices, err := svc.GetLTP(ctx, ps)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch prices")
+			logger.ErrorContext(ctx, "ltp fetch failed", "err", err, "pairs", service.JoinPairs(ps))
+			return
+		}
+		payload := svc.BuildResponse(prices, <-lastTrades)
+		writeJSON(w, http.StatusOK, payload)
+	}
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/service"
+)
+
+// ohlcHandler serves GET /api/v1/ohlc?pair=&interval=&since=.
+// interval is in minutes (default 1), since is unix seconds (optional).
+func ohlcHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		pair, err := service.ParsePairQuery(q.Get("pair"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		interval := 1
+		if v := q.Get("interval"); v != "" {
+			interval, err = strconv.Atoi(v)
+			if err != nil || !kraken.ValidInterval(interval) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid interval: " + v})
+				return
+			}
+		}
+		var since int64
+		if v := q.Get("since"); v != "" {
+			since, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || since < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid since: " + v})
+				return
+			}
+		}
+		ctx := r.Context()
+
+		candles, err := svc.GetOHLC(ctx, pair, interval, since)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch ohlc")
+			logger.ErrorContext(ctx, "ohlc fetch failed", "err", err, "pair", pair, "interval", interval)
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildOHLCResponse(pair, interval, candles))
+	}
+}
@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bitcoin-prices/internal/cache"
+	"bitcoin-prices/internal/clock"
+)
+
+// RateLimit is a token bucket: Rate requests per second on average with
+// bursts of up to Burst. A zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits is the default limit and overrides by route, as mounted.
+type RateLimits struct {
+	Default RateLimit
+	Routes  map[string]RateLimit
+}
+
+func (l *RateLimits) forRoute(route string) RateLimit {
+	if rl, ok := l.Routes[route]; ok {
+		return rl
+	}
+	return l.Default
+}
+
+// RateLimiter keeps a token bucket per client and route. A client is its API
+// key when the request was authenticated, its IP otherwise. At most
+// maxClients buckets are tracked, the least recently used are dropped first;
+// idle buckets are dropped once they would have refilled anyway.
+type RateLimiter struct {
+	limits atomic.Pointer[RateLimits]
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	buckets *cache.TTLCache[string, bucket]
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter enforcing limits until Close.
+func NewRateLimiter(limits RateLimits, maxClients int, clk clock.Clock) *RateLimiter {
+	if clk == nil {
+		clk = clock.Real
+	}
+	l := &RateLimiter{clock: clk}
+	l.buckets = cache.New(time.Minute,
+		cache.WithMaxEntries[string, bucket](maxClients),
+		cache.WithJanitor[string, bucket](time.Minute),
+		cache.WithClock[string, bucket](clk))
+	l.SetLimits(limits)
+	return l
+}
+
+// SetLimits replaces the limits. Clients keep their buckets, capped to the
+// new burst.
+func (l *RateLimiter) SetLimits(limits RateLimits) {
+	l.limits.Store(&limits)
+}
+
+// Close stops dropping idle buckets in the background.
+func (l *RateLimiter) Close() { l.buckets.Close() }
+
+// take removes a token from the client's bucket for route. It reports the
+// bucket's remaining tokens, when it will be full again, and, if no token was
+// left, when the next one is available.
+func (l *RateLimiter) take(rl RateLimit, route, client string) (ok bool, remaining int, reset, retry time.Duration) {
+	burst := float64(max(rl.Burst, 1))
+	now := l.clock.Now()
+	key := route + " " + client
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, found := l.buckets.Get(key)
+	if !found {
+		b = bucket{tokens: burst, last: now}
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rl.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		retry = seconds((1 - b.tokens) / rl.Rate)
+	}
+	reset = seconds((burst - b.tokens) / rl.Rate)
+	// a bucket idle until it is full again is the same as a new one
+	l.buckets.SetWithTTL(key, b, max(reset, time.Second))
+	return ok, int(b.tokens), reset, retry
+}
+
+func seconds(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+
+// withRateLimit limits requests to route per client, reporting the client's
+// budget in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers,
+// and answering 429 with Retry-After once it is used up.
+func withRateLimit(l *RateLimiter, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl := l.limits.Load().forRoute(route)
+		if rl.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := "ip:" + clientIP(r)
+		if id := KeyID(r.Context()); id != "" {
+			client = "key:" + id
+		}
+		ok, remaining, reset, retry := l.take(rl, route, client)
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(max(rl.Burst, 1)))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+		if !ok {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(retry), 1)))
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": "rate limit exceeded"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int { return int(math.Ceil(d.Seconds())) }
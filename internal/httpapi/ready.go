@@ -0,0 +1,26 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"bitcoin-prices/internal/service"
+)
+
+// readyHandler serves GET /api/ready. It returns 503 when the service cannot
+// currently serve fresh prices; /api/health stays a pure liveness check.
+func readyHandler(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+
+		rd := svc.Readiness(ctx)
+		code := http.StatusOK
+		if !rd.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, service.BuildReadyResponse(rd))
+	}
+}
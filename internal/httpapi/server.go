@@ -2,109 +2,788 @@ package httpapi
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"log/slog"
-	"net"
+	"math"
 	"net/http"
-	"os"
-	"strconv"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"bitcoin-prices/internal/alerts"
+	"bitcoin-prices/internal/cache"
+	"bitcoin-prices/internal/certs"
+	"bitcoin-prices/internal/clock"
+	"bitcoin-prices/internal/config"
+	"bitcoin-prices/internal/decimal"
 	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/metrics"
+	"bitcoin-prices/internal/pairs"
+	"bitcoin-prices/internal/requestid"
 	"bitcoin-prices/internal/service"
+	"bitcoin-prices/internal/tracing"
 )
 
 type Server struct {
 	log     *slog.Logger
 	server  *http.Server
+	https   *http.Server // separate HTTPS listener, if any
+	certs   *certs.Reloader
 	service *service.Service
+	alerts  *alerts.Manager
+
+	statePath string
+	stopState chan struct{}
+	stateDone chan struct{}
+	stopOnce  sync.Once // guards the teardown in Shutdown
+
+	reloadMu sync.Mutex
+	cfg      config.Config
+	load     func() (config.Config, error)
+	level    *slog.LevelVar
+	kraken   *kraken.Client
+	auth     *Authenticator
+	limiter  *RateLimiter
+
+	stopTracing func(context.Context) error
+	clock       clock.Clock
+}
+
+// ServerOption configures optional Server behaviour.
+type ServerOption func(*Server)
+
+// WithConfigLoader sets how Reload reads the configuration again, normally
+// config.Load with the process arguments and environment.
+func WithConfigLoader(load func() (config.Config, error)) ServerOption {
+	return func(s *Server) { s.load = load }
+}
+
+// WithClock sets the clock the server and everything it starts use for
+// expiry, quotas, polling and backoff (default clock.Real).
+func WithClock(clk clock.Clock) ServerOption {
+	return func(s *Server) { s.clock = clk }
+}
+
+// WithLevelVar lets Reload and /api/admin/log-level change the level of the
+// server's logger, which must be built on level (see logging.New).
+func WithLevelVar(level *slog.LevelVar) ServerOption {
+	return func(s *Server) { s.level = level }
+}
+
+// NewServer builds an HTTP server from a validated configuration (see
+// config.Load) that logs to logger. With TLS configured it serves HTTPS, on
+// the main port or a separate one, and fails if the certificate cannot be
+// loaded. If it fails, whatever it started is stopped again.
+func NewServer(cfg config.Config, logger *slog.Logger, sopts ...ServerOption) (_ *Server, err error) {
+	srv := &Server{log: logger, cfg: cfg, clock: clock.Real}
+	for _, opt := range sopts {
+		opt(srv)
+	}
+	clk := srv.clock
+
+	// steps that can fail without starting anything come first
+	proxies, err := ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	// what is started from here on is stopped, in reverse, on failure
+	var cleanup []func()
+	defer func() {
+		if err != nil {
+			for i := len(cleanup) - 1; i >= 0; i-- {
+				cleanup[i]()
+			}
+		}
+	}()
+
+	var reloader *certs.Reloader
+	if cfg.TLS.Enabled() {
+		clientAuth := tls.RequireAndVerifyClientCert
+		if cfg.TLS.ClientAuth == "verify_if_given" {
+			clientAuth = tls.VerifyClientCertIfGiven
+		}
+		reloader, err = certs.New(certs.Config{
+			CertFile:     cfg.TLS.CertFile,
+			KeyFile:      cfg.TLS.KeyFile,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			ClientAuth:   clientAuth,
+			Interval:     cfg.TLS.ReloadInterval.D(),
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		cleanup = append(cleanup, reloader.Close)
+	}
+	pairs.SetEnabled(cfg.Pairs.Enabled)
+	ttl := cfg.Cache.TTL.D()
+	// validated by config.Load
+	rounding, _ := decimal.ParseRoundingMode(cfg.Convert.Rounding)
+
+	reg := metrics.NewRegistry()
+	guard := service.GuardConfig{
+		MaxDeviation: cfg.Guard.MaxDeviation / 100,
+		PerPair:      make(map[string]float64, len(cfg.Guard.PerPair)),
+		Window:       cfg.Guard.Window.D(),
+	}
+	for pair, pct := range cfg.Guard.PerPair {
+		guard.PerPair[pair] = pct / 100
+	}
+
+	tp, stopTracing, err := tracing.New(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cleanup = append(cleanup, func() { stopTracing(context.Background()) })
+
+	opts := []service.Option{
+		service.WithTracerProvider(tp),
+		service.WithLogger(logger),
+		service.WithMetrics(reg),
+		service.WithGuard(guard),
+		service.WithStaleAfter(cfg.LTP.StaleAfter.D()),
+		service.WithMaxCacheEntries(cfg.Cache.MaxEntries),
+		service.WithMaxClockSkew(cfg.Ready.MaxClockSkew.D()),
+		service.WithRounding(rounding),
+		service.WithChunkSize(cfg.Kraken.BatchSize),
+		service.WithSnapshotRetention(cfg.LTP.SnapshotRetention.D()),
+		service.WithSnapshotTimeout(snapshotTimeout(cfg)),
+		service.WithClock(clk),
+	}
+	if addr := cfg.Redis.Addr; addr != "" {
+		// falls back to memory while Redis is unreachable, bounded like
+		// the service's own caches
+		opts = append(opts, service.WithQuoteCache(cache.NewRedis(cache.RedisConfig{
+			Addr:     addr,
+			Password: cfg.Redis.Password,
+			Prefix:   cfg.Redis.Prefix,
+			OnError: func(err error) {
+				logger.Warn("shared cache unreachable, using in-memory cache", "addr", addr, "err", err)
+			},
+		}, ttl,
+			cache.WithMaxEntries[string, service.Quote](cfg.Cache.MaxEntries),
+			cache.WithJanitor[string, service.Quote](max(ttl, time.Second)),
+			cache.WithClock[string, service.Quote](clk))))
+	}
+
+	// Alerts are fed by every fresh price the service fetches, and polled
+	// for when there is no traffic.
+	var am *alerts.Manager
+	if store, err := alerts.OpenStore(cfg.Alerts.File); err != nil {
+		// don't risk overwriting an unreadable file; run without alerts instead
+		logger.Error("alerts disabled: cannot load alerts file", "err", err)
+	} else {
+		// validated by config.Load
+		allowed, _ := alerts.ParseNetworks(cfg.Alerts.AllowedNetworks)
+		d := alerts.NewDeliverer(alerts.DelivererConfig{
+			Attempts:           cfg.Alerts.WebhookAttempts,
+			DeadLetterPath:     cfg.Alerts.DeadLetterFile,
+			DeadLetterMaxBytes: int64(cfg.Alerts.DeadLetterMaxBytes),
+			AllowedNetworks:    allowed,
+			Clock:              clk,
+		}, logger)
+		am = alerts.NewManager(store, d, logger, clk)
+		cleanup = append(cleanup, am.Close)
+		opts = append(opts, service.WithQuoteListener(func(pair string, q service.Quote) {
+			am.Observe(pair, q.Price, q.FetchedAt)
+		}))
+	}
+
+	kc := kraken.NewClient(cfg.Kraken.BaseURL, &http.Client{Timeout: cfg.Kraken.Timeout.D()}, cfg.Kraken.Retries, kraken.WithTracerProvider(tp))
+	svc := service.New(kc, ttl, opts...)
+	cleanup = append(cleanup, svc.Close)
+	if am != nil && cfg.Alerts.PollInterval > 0 {
+		am.Poll(alertPrices(svc), cfg.Alerts.PollInterval.D())
+	}
+	statePath := cfg.Cache.StateFile
+	if statePath != "" {
+		// a bad state file only costs a cold start
+		if info, err := svc.LoadState(statePath); err != nil {
+			logger.Warn("cache state not loaded", "path", statePath, "err", err)
+		} else if !info.SavedAt.IsZero() {
+			logger.Info("cache state loaded", "path", statePath, "saved_at", info.SavedAt,
+				"fresh", info.Fresh, "stale", info.Stale, "ohlc_series", info.Series)
+		}
+	}
+
+	auth := NewAuthenticator(apiKeys(cfg.Auth.Keys), clk)
+	limiter := NewRateLimiter(rateLimits(cfg.RateLimit), cfg.RateLimit.MaxClients, clk)
+	srv.service, srv.alerts, srv.certs, srv.statePath = svc, am, reloader, statePath
+	srv.kraken, srv.auth, srv.limiter, srv.stopTracing = kc, auth, limiter, stopTracing
+
+	routeTimeouts := make(map[string]time.Duration, len(cfg.Server.RouteTimeouts))
+	for route, d := range cfg.Server.RouteTimeouts {
+		routeTimeouts[route] = d.D()
+	}
+	hopts := []HandlerOption{WithMetrics(reg), WithTimeouts(cfg.Server.HandlerTimeout.D(), routeTimeouts), WithAuth(auth), WithRateLimit(limiter), WithTrustedProxies(proxies), WithTracing(tp), WithAccessLog(cfg.Log.AccessSample)}
+	if am != nil {
+		hopts = append(hopts, WithAlerts(am))
+	}
+	if cfg.Admin.Token != "" {
+		hopts = append(hopts, WithReload(cfg.Admin.Token, srv.Reload))
+		if srv.level != nil {
+			hopts = append(hopts, WithLogLevel(cfg.Admin.Token, srv.level))
+		}
+	}
+	mux := NewHandler(logger, svc, hopts...)
+
+	newHTTPServer := func(addr string) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.D(),
+			ReadTimeout:       cfg.Server.ReadTimeout.D(),
+			WriteTimeout:      cfg.Server.WriteTimeout.D(),
+			IdleTimeout:       cfg.Server.IdleTimeout.D(),
+			MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		}
+	}
+	srv.server = newHTTPServer(cfg.Addr())
+	if reloader != nil {
+		if addr := cfg.TLSAddr(); addr != "" {
+			srv.https = newHTTPServer(addr)
+			srv.https.TLSConfig = reloader.TLSConfig()
+		} else {
+			srv.server.TLSConfig = reloader.TLSConfig()
+		}
+	}
+
+	if statePath != "" {
+		srv.stopState = make(chan struct{})
+		srv.stateDone = make(chan struct{})
+		interval := cfg.Cache.StateInterval.D()
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		go srv.saveStatePeriodically(clk.NewTicker(interval))
+	}
+	return srv, nil
+}
+
+// HandlerOption enables optional API features in NewHandler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	alerts        *alerts.Manager
+	metrics       *metrics.Registry
+	adminToken    string
+	adminReload   ReloadFunc
+	timeout       time.Duration
+	routeTimeouts map[string]time.Duration
+	auth          *Authenticator
+	limiter       *RateLimiter
+	proxies       *TrustedProxies
+	tracer        trace.Tracer
+	logLevel      *slog.LevelVar
+	accessSample  map[string]float64
+}
+
+// WithAuth requires an API key accepted by a on the API routes. The probes
+// (/api/health, /api/ready) and /metrics stay open for orchestrators and
+// scrapers; the admin endpoints have their own token.
+func WithAuth(a *Authenticator) HandlerOption {
+	return func(c *handlerConfig) { c.auth = a }
 }
 
-// NewServer builds an HTTP server bound to addr.
-// Env:
-// - CACHE_TTL (seconds, default 10)
-// - KRAKEN_BASE_URL (default https://api.kraken.com)
-// - KRAKEN_RETRIES (default 2)
-func NewServer(addr string) *Server {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+// WithRateLimit limits the requests each client makes to the API routes
+// with l.
+func WithRateLimit(l *RateLimiter) HandlerOption {
+	return func(c *handlerConfig) { c.limiter = l }
+}
 
-	// Config
-	ttl := parseEnvInt("CACHE_TTL", 10)
-	krBase := getenv("KRAKEN_BASE_URL", "https://api.kraken.com")
-	retries := parseEnvInt("KRAKEN_RETRIES", 2)
+// WithTrustedProxies believes the forwarding headers set by p when
+// attributing requests to clients. Without it the peer address is the client.
+func WithTrustedProxies(p *TrustedProxies) HandlerOption {
+	return func(c *handlerConfig) { c.proxies = p }
+}
 
-	kc := kraken.NewClient(krBase, &http.Client{Timeout: 5 * time.Second}, retries)
-	svc := service.New(kc, time.Duration(ttl)*time.Second)
+// WithTracing records a server span per API request with tp, continuing
+// the caller's trace if the request carries a W3C traceparent header.
+func WithTracing(tp trace.TracerProvider) HandlerOption {
+	return func(c *handlerConfig) { c.tracer = tp.Tracer("bitcoin-prices/internal/httpapi") }
+}
 
-	mux := NewHandler(logger, svc)
+// defaultHandlerTimeout matches config.Default: it exceeds the Kraken retry
+// budget of the default client timeout and retries.
+const defaultHandlerTimeout = 8 * time.Second
 
-	hs := &http.Server{
-		Addr:              addr,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
-		IdleTimeout:       30 * time.Second,
+// WithTimeouts sets the request deadline for API routes and per-route
+// overrides by path; an override of 0 removes the deadline and the server's
+// write timeout for that route (streaming).
+func WithTimeouts(timeout time.Duration, perRoute map[string]time.Duration) HandlerOption {
+	return func(c *handlerConfig) { c.timeout, c.routeTimeouts = timeout, perRoute }
+}
+
+func (c *handlerConfig) timeoutFor(route string) time.Duration {
+	if d, ok := c.routeTimeouts[route]; ok {
+		return d
 	}
+	return c.timeout
+}
 
-	return &Server{log: logger, server: hs, service: svc}
+// WithReload serves POST /api/admin/reload, which calls reload, to requests
+// bearing token.
+func WithReload(token string, reload ReloadFunc) HandlerOption {
+	return func(c *handlerConfig) { c.adminToken, c.adminReload = token, reload }
+}
+
+// WithLogLevel serves /api/admin/log-level, which reads and sets level, to
+// requests bearing token.
+func WithLogLevel(token string, level *slog.LevelVar) HandlerOption {
+	return func(c *handlerConfig) { c.adminToken, c.logLevel = token, level }
+}
+
+// WithAccessLog sets the share of successful requests logged by route, 0
+// logging none. Routes not listed are all logged, except the probes and
+// /metrics, which are not logged at all. Failed requests (status >= 400) are
+// always logged.
+func WithAccessLog(sample map[string]float64) HandlerOption {
+	return func(c *handlerConfig) { c.accessSample = sample }
+}
+
+// quietRoutes are not logged unless WithAccessLog says otherwise.
+var quietRoutes = map[string]bool{"/api/health": true, "/api/ready": true, "/metrics": true}
+
+func (c *handlerConfig) sampleFor(route string) float64 {
+	if v, ok := c.accessSample[route]; ok {
+		return v
+	}
+	if quietRoutes[route] {
+		return 0
+	}
+	return 1
+}
+
+// WithMetrics serves reg in the Prometheus text format on /metrics.
+func WithMetrics(reg *metrics.Registry) HandlerOption {
+	return func(c *handlerConfig) { c.metrics = reg }
+}
+
+// WithAlerts serves the alerts CRUD endpoints under /api/v1/alerts.
+func WithAlerts(m *alerts.Manager) HandlerOption {
+	return func(c *handlerConfig) { c.alerts = m }
 }
 
 // NewHandler builds the HTTP handler (mux) for the API using provided logger and service.
-func NewHandler(logger *slog.Logger, svc *service.Service) http.Handler {
+func NewHandler(logger *slog.Logger, svc *service.Service, opts ...HandlerOption) http.Handler {
+	cfg := handlerConfig{timeout: defaultHandlerTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/api/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	routes := make(map[string]bool)
+	// protect requires an API key for route if authentication is enabled.
+	protect := func(route string, h http.Handler) http.Handler {
+		if cfg.auth == nil {
+			return h
+		}
+		return withAuth(cfg.auth, route, h)
+	}
+	// limit applies the per-client rate limit, after authentication so
+	// clients with an API key are told apart by key.
+	limit := func(route string, h http.Handler) http.Handler {
+		if cfg.limiter == nil {
+			return h
+		}
+		return withRateLimit(cfg.limiter, route, h)
+	}
+	// traced wraps h in a server span if tracing is enabled.
+	traced := func(route string, h http.Handler) http.Handler {
+		if cfg.tracer == nil {
+			return h
+		}
+		return withTracing(cfg.tracer, route, h)
+	}
+	// logged resolves the client IP and writes the access log for route.
+	logged := func(route string, h http.Handler) http.Handler {
+		routes[route] = true
+		return withLogging(logger, cfg.sampleFor(route), withClientIP(cfg.proxies, h))
+	}
+	// handle mounts an API route with its deadline, rate limit,
+	// authentication, request logging and tracing.
+	handle := func(route string, h http.Handler) {
+		mux.Handle(route, traced(route, logged(route, protect(route, limit(route, withDeadline(cfg.timeoutFor(route), h))))))
+	}
+	mux.Handle("/api/health", logged("/api/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
-	}))
-	mux.Handle("/api/v1/ltp", withLogging(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		pairsQ := r.URL.Query().Get("pairs")
-		ps, err := service.ParsePairsQuery(pairsQ)
-		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
-			return
+	})))
+	mux.Handle("/api/ready", traced("/api/ready", logged("/api/ready", withDeadline(cfg.timeoutFor("/api/ready"), readyHandler(svc)))))
+	if cfg.metrics != nil {
+		mux.Handle("/metrics", logged("/metrics", cfg.metrics.Handler()))
+	}
+	// the admin endpoints are not behind API keys: the admin token
+	// authenticates them
+	if cfg.adminReload != nil {
+		route := "/api/admin/reload"
+		mux.Handle(route, logged(route, withDeadline(cfg.timeoutFor(route), reloadHandler(logger, cfg.adminToken, cfg.adminReload))))
+	}
+	if cfg.logLevel != nil {
+		route := "/api/admin/log-level"
+		mux.Handle(route, logged(route, logLevelHandler(logger, cfg.adminToken, cfg.logLevel)))
+	}
+	handle("/api/v1/ltp", ltpHandler(logger, svc))
+	handle("/api/v1/ltp:batch", ltpBatchHandler(logger, svc))
+	handle("/api/v1/ohlc", ohlcHandler(logger, svc))
+	handle("/api/v1/trades", tradesHandler(logger, svc))
+	handle("/api/v1/orderbook", orderBookHandler(logger, svc))
+	handle("/api/v1/spread", spreadHandler(logger, svc))
+	handle("/api/v1/convert", convertHandler(logger, svc))
+	handle("/api/v1/valuation", valuationHandler(logger, svc))
+	if cfg.alerts != nil {
+		handle("/api/v1/alerts", alertsHandler(logger, cfg.alerts))
+		handle("/api/v1/alerts/{id}", alertHandler(logger, cfg.alerts))
+	}
+	for route := range cfg.routeTimeouts {
+		if !routes[route] {
+			logger.Warn("timeout configured for unknown route", "route", route)
 		}
-		ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
-		defer cancel()
-
-		prices, err := svc.GetLTP(ctx, ps)
-		if err != nil {
-			code := http.StatusBadGateway
-			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-				code = http.StatusGatewayTimeout
+	}
+	if cfg.limiter != nil {
+		for route := range cfg.limiter.limits.Load().Routes {
+			if !routes[route] {
+				logger.Warn("rate limit configured for unknown route", "route", route)
 			}
-			writeJSON(w, code, map[string]any{"error": "failed to fetch prices"})
-			logger.Error("ltp fetch failed", "err", err, "pairs", service.JoinPairs(ps))
-			return
 		}
-		payload := service.BuildResponse(prices)
-		writeJSON(w, http.StatusOK, payload)
-	})))
-	return mux
+	}
+	for route := range cfg.accessSample {
+		if !routes[route] {
+			logger.Warn("access log sampling configured for unknown route", "route", route)
+		}
+	}
+	return withRequestID(mux)
 }
 
+// Start serves until Shutdown. With separate HTTP and HTTPS listeners it
+// returns the first listener's error.
 func (s *Server) Start() error {
-	s.log.Info("Starting HTTP server", "addr", s.server.Addr)
-	return s.server.ListenAndServe()
+	if s.https == nil {
+		return s.serve(s.server)
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- s.serve(s.https) }()
+	go func() { errc <- s.serve(s.server) }()
+	return <-errc
 }
 
+func (s *Server) serve(hs *http.Server) error {
+	if hs.TLSConfig != nil {
+		s.log.Info("Starting HTTPS server", "addr", hs.Addr, "mtls", s.cfg.TLS.ClientCAFile != "")
+		return hs.ListenAndServeTLS("", "")
+	}
+	s.log.Info("Starting HTTP server", "addr", hs.Addr)
+	return hs.ListenAndServe()
+}
+
+// Shutdown stops the listeners and releases everything the server started,
+// saving the cache state if configured. Calling it again only waits for the
+// listeners; the rest is torn down once.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.log.Info("Shutting down HTTP server")
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	if s.https != nil {
+		err = errors.Join(err, s.https.Shutdown(ctx))
+	}
+	s.stopOnce.Do(func() {
+		if s.certs != nil {
+			s.certs.Close()
+		}
+		s.limiter.Close()
+		if s.alerts != nil {
+			s.alerts.Close()
+		}
+		if s.statePath != "" {
+			close(s.stopState)
+			<-s.stateDone
+			s.saveState()
+		}
+		s.service.Close()
+		// flushes buffered spans, the last ones included
+		err = errors.Join(err, s.stopTracing(ctx))
+	})
+	return err
+}
+
+// Reload reads the configuration again and applies the settings that are safe
+// to change at runtime: log level, enabled pairs, cache TTL, Kraken retries,
+// API keys and rate limits. Other changes are logged as needing a restart and
+// not applied. An invalid configuration, or one that is invalid combined with
+// the settings kept until a restart, is rejected and the current one stays
+// active.
+func (s *Server) Reload() ([]config.Change, error) {
+	if s.load == nil {
+		return nil, errors.New("no configuration loader")
+	}
+	next, err := s.load()
+	if err != nil {
+		s.log.Error("config reload rejected, keeping current configuration", "err", err)
+		return nil, err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	cfg, changes, err := config.Reload(s.cfg, next)
+	if err != nil {
+		s.log.Error("config reload rejected, keeping current configuration", "err", err)
+		return nil, err
+	}
+	for _, c := range changes {
+		if c.Reloadable {
+			s.log.Info("config changed", "key", c.Key, "old", c.Old, "new", c.New)
+		} else {
+			s.log.Warn("config change needs a restart, not applied", "key", c.Key, "old", c.Old, "new", c.New)
+		}
+	}
+	// only an edited log.level overrides a level set at runtime
+	if s.level != nil && cfg.Log.Level != s.cfg.Log.Level {
+		s.level.UnmarshalText([]byte(cfg.Log.Level))
+	}
+	pairs.SetEnabled(cfg.Pairs.Enabled)
+	s.service.SetTTL(cfg.Cache.TTL.D())
+	s.kraken.SetRetries(cfg.Kraken.Retries)
+	s.service.SetSnapshotTimeout(snapshotTimeout(cfg))
+	s.auth.SetKeys(apiKeys(cfg.Auth.Keys))
+	s.limiter.SetLimits(rateLimits(cfg.RateLimit))
+	s.cfg = cfg
+	s.log.Info("config reloaded", "changes", len(changes))
+	return changes, nil
+}
+
+// apiKeys converts validated configured keys.
+func apiKeys(keys config.APIKeys) []APIKey {
+	out := make([]APIKey, 0, len(keys))
+	for _, k := range keys {
+		digest, _ := k.Digest()
+		out = append(out, APIKey{ID: k.ID, Digest: digest, Scopes: k.Scopes, Quota: k.Quota, QuotaWindow: k.QuotaWindow.D()})
+	}
+	return out
+}
+
+// alertPrices serves the alerts poller from the service, cached prices
+// included: with a shared cache they may come from another replica.
+func alertPrices(svc *service.Service) alerts.PriceFunc {
+	return func(ctx context.Context, ps []string) (map[string]alerts.Price, error) {
+		quotes, err := svc.GetQuotes(ctx, ps)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]alerts.Price, len(quotes))
+		for p, q := range quotes {
+			out[p] = alerts.Price{Value: q.Price, At: q.FetchedAt}
+		}
+		return out, nil
+	}
+}
+
+// snapshotTimeout bounds the Kraken call behind a consistent LTP snapshot by
+// the /api/v1/ltp deadline, which a validated configuration keeps above the
+// Kraken retry budget; without a deadline the budget itself bounds it.
+func snapshotTimeout(cfg config.Config) time.Duration {
+	d := cfg.Server.HandlerTimeout.D()
+	if rd, ok := cfg.Server.RouteTimeouts["/api/v1/ltp"]; ok {
+		d = rd.D()
+	}
+	return max(d, kraken.RetryBudget(cfg.Kraken.Timeout.D(), cfg.Kraken.Retries))
+}
+
+// rateLimits converts validated configured limits. A burst of 0 is the rate
+// rounded up.
+func rateLimits(c config.RateLimitConfig) RateLimits {
+	limit := func(rate float64, burst int) RateLimit {
+		if burst == 0 {
+			burst = max(int(math.Ceil(rate)), 1)
+		}
+		return RateLimit{Rate: rate, Burst: burst}
+	}
+	out := RateLimits{Default: limit(c.Rate, c.Burst), Routes: make(map[string]RateLimit, len(c.Routes))}
+	for route, l := range c.Routes {
+		out.Routes[route] = limit(l.Rate, l.Burst)
+	}
+	return out
+}
+
+// saveStatePeriodically saves the state on every tick of t until Shutdown.
+// t is started by the caller, so saves are due from when it returns.
+func (s *Server) saveStatePeriodically(t clock.Ticker) {
+	defer close(s.stateDone)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			s.saveState()
+		case <-s.stopState:
+			return
+		}
+	}
+}
+
+func (s *Server) saveState() {
+	if err := s.service.SaveState(s.statePath); err != nil {
+		s.log.Error("cache state save failed", "path", s.statePath, "err", err)
+		return
+	}
+	s.log.Debug("cache state saved", "path", s.statePath)
+}
+
+// requestInfo collects details inner middleware learns about a request (such
+// as the API key) for the request log.
+type requestInfo struct {
+	keyID string
+	ip    string
+}
+
+type requestInfoKey struct{}
+
+func withKeyID(r *http.Request, id string) *http.Request {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.keyID = id
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{keyID: id}))
+}
+
+func withRequestIP(r *http.Request, ip string) *http.Request {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.ip = ip
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{ip: ip}))
+}
+
+// KeyID returns the ID of the API key a request was authenticated with, or "".
+func KeyID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.keyID
+	}
+	return ""
 }
 
 // withLogging is a middleware that logs requests using the provided logger.
-func withLogging(log *slog.Logger, next http.Handler) http.Handler {
+// Of the successful requests (status < 400) only the share sample is
+// logged, evenly spread: 0.1 logs every tenth.
+func withLogging(log *slog.Logger, sample float64, next http.Handler) http.Handler {
+	var n atomic.Uint64
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		rw := &respWriter{ResponseWriter: w, status: 200}
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
 		next.ServeHTTP(rw, r)
+		if rw.status < 400 && !sampled(n.Add(1), sample) {
+			return
+		}
 		lat := time.Since(start)
-		log.Info("http", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery, "ip", clientIP(r), "status", rw.status, "dur_ms", lat.Milliseconds())
+		attrs := []any{"method", r.Method, "path", r.URL.Path, "query", redactQuery(r.URL.RawQuery), "ip", clientIP(r), "status", rw.status, "dur_ms", lat.Milliseconds()}
+		if info.keyID != "" {
+			attrs = append(attrs, "key", info.keyID)
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			attrs = append(attrs, "trace_id", sc.TraceID().String())
+		}
+		log.InfoContext(r.Context(), "http", attrs...)
+	})
+}
+
+// withRequestID tags each request with the client's X-Request-ID, or a new
+// ID if it sent none or an unusable one, for logs (see requestid.Handler),
+// Kraken calls and the response, including error bodies.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// sampled reports whether the nth (1-based) request is logged when the share
+// sample of requests is.
+func sampled(n uint64, sample float64) bool {
+	switch {
+	case sample >= 1:
+		return true
+	case sample <= 0:
+		return false
+	}
+	return uint64(float64(n)*sample) > uint64(float64(n-1)*sample)
+}
+
+// secretParams are query parameters whose values are not logged.
+var secretParams = map[string]bool{
+	"api_key": true, "apikey": true, "api-key": true, "key": true,
+	"token": true, "access_token": true, "secret": true, "password": true,
+}
+
+// redactQuery replaces the values of secretParams in a raw query.
+func redactQuery(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	parts := strings.Split(raw, "&")
+	redacted := false
+	for i, p := range parts {
+		k, _, _ := strings.Cut(p, "=")
+		if name, err := url.QueryUnescape(k); err == nil && secretParams[strings.ToLower(name)] {
+			parts[i] = k + "=REDACTED"
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	return strings.Join(parts, "&")
+}
+
+// withTracing records a server span named after the method and route,
+// continuing the trace from the request's traceparent header.
+func withTracing(tracer trace.Tracer, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("request_id", requestid.FromContext(ctx)),
+		))
+		defer span.End()
+		rw := &respWriter{ResponseWriter: w, status: 200}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.status))
+		if rw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+// withDeadline bounds the request context by d. With d == 0 the request has
+// no deadline and the server's write timeout is lifted, for streaming.
+func withDeadline(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d <= 0 {
+			// fails only for writers without deadline support, e.g. in tests
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -115,7 +794,17 @@ type respWriter struct {
 
 func (w *respWriter) WriteHeader(code int) { w.status = code; w.ResponseWriter.WriteHeader(code) }
 
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *respWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// writeJSON writes v with status. Error bodies ({"error": ...}) get the
+// request ID set by withRequestID.
 func writeJSON(w http.ResponseWriter, status int, v any) {
+	if m, ok := v.(map[string]any); ok && status >= 400 && m["error"] != nil {
+		if id := w.Header().Get(requestid.Header); id != "" {
+			m["request_id"] = id
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
@@ -123,34 +812,11 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = enc.Encode(v)
 }
 
-func clientIP(r *http.Request) string {
-	// X-Forwarded-For first if present
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}
-
-func parseEnvInt(key string, def int) int {
-	v := os.Getenv(key)
-	if v == "" {
-		return def
-	}
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		return def
+// writeUpstreamError maps an upstream fetch error to 504 (timeout) or 502.
+func writeUpstreamError(w http.ResponseWriter, err error, msg string) {
+	code := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		code = http.StatusGatewayTimeout
 	}
-	return i
+	writeJSON(w, code, map[string]any{"error": msg})
 }
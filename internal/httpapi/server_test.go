@@ -2,20 +2,51 @@ package httpapi
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"bitcoin-prices/internal/alerts"
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/config"
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/metrics"
+	"bitcoin-prices/internal/pairs"
+	"bitcoin-prices/internal/requestid"
 	"bitcoin-prices/internal/service"
 )
 
 type mockKraken struct {
-	resp map[string]float64
-	err  error
+	resp   map[string]float64
+	err    error
+	status string
 }
 
 func (m *mockKraken) GetLastTradeClosed(ctx context.Context, pairs []string) (map[string]float64, error) {
@@ -29,12 +60,47 @@ func (m *mockKraken) GetLastTradeClosed(ctx context.Context, pairs []string) (ma
 	return out, m.err
 }
 
+func (m *mockKraken) GetOHLC(ctx context.Context, krakenPair string, interval int, since int64) (*kraken.OHLC, error) {
+	now := time.Now().Unix()
+	return &kraken.OHLC{Candles: []kraken.Candle{
+		{Time: now - 120, Open: 1, High: 2, Low: 1, Close: 2},
+		{Time: now, Open: 2, High: 3, Low: 2, Close: 3},
+	}, Last: now - 120}, m.err
+}
+
+func (m *mockKraken) GetTrades(ctx context.Context, krakenPair string, since int64) (*kraken.Trades, error) {
+	return &kraken.Trades{Trades: []kraken.Trade{
+		{Price: m.resp[krakenPair], Volume: 0.1, Time: time.Now().Add(-time.Second), Side: "buy", OrderType: "limit", ID: 7},
+	}}, m.err
+}
+
+func (m *mockKraken) GetDepth(ctx context.Context, krakenPair string, count int) (*kraken.OrderBook, error) {
+	p := m.resp[krakenPair]
+	return &kraken.OrderBook{
+		Bids: []kraken.Level{{Price: p - 1, Volume: 1}, {Price: p - 2, Volume: 2}},
+		Asks: []kraken.Level{{Price: p + 1, Volume: 1}, {Price: p + 2, Volume: 2}},
+	}, m.err
+}
+
+func (m *mockKraken) GetSpread(ctx context.Context, krakenPair string, since int64) (*kraken.Spreads, error) {
+	p := m.resp[krakenPair]
+	return &kraken.Spreads{Points: []kraken.SpreadPoint{{Time: time.Now(), Bid: p - 1, Ask: p + 1}}}, m.err
+}
+
+func (m *mockKraken) GetSystemStatus(ctx context.Context) (*kraken.SystemStatus, error) {
+	return &kraken.SystemStatus{Status: m.status}, m.err
+}
+
+func (m *mockKraken) GetServerTime(ctx context.Context) (time.Time, error) {
+	return time.Now(), m.err
+}
+
 func newTestHandler() http.Handler {
 	mk := &mockKraken{resp: map[string]float64{
 		"XXBTZUSD": 52000.12,
 		"XXBTZEUR": 50000.12,
 		"XXBTZCHF": 49000.12,
-	}}
+	}, status: kraken.StatusOnline}
 	svc := service.New(mk, time.Minute)
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	return NewHandler(logger, svc)
@@ -87,6 +153,44 @@ func TestLTP_InvalidPair(t *testing.T) {
 	}
 }
 
+func TestLTP_ConsistentSnapshot(t *testing.T) {
+	h := newTestHandler()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD,BTC/EUR&consistent=true", nil))
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		LTP        []map[string]any `json:"ltp"`
+		SnapshotID string           `json:"snapshot_id"`
+		SnapshotAt string           `json:"snapshot_at"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if len(body.LTP) != 2 || body.SnapshotID == "" || body.SnapshotAt == "" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+
+	again := httptest.NewRecorder()
+	h.ServeHTTP(again, httptest.NewRequest("GET", "/api/v1/ltp?snapshot="+body.SnapshotID, nil))
+	if again.Code != 200 || again.Body.String() != rec.Body.String() {
+		t.Fatalf("expected the same snapshot, got %d: %s", again.Code, again.Body.String())
+	}
+
+	for target, code := range map[string]int{
+		"/api/v1/ltp?snapshot=unknown":              404,
+		"/api/v1/ltp?snapshot=abc&pairs=BTC/USD":    400,
+		"/api/v1/ltp?pairs=BTC/USD&consistent=yes!": 400,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != code {
+			t.Fatalf("%s: expected %d, got %d", target, code, rec.Code)
+		}
+	}
+}
+
 func TestLTP_MethodNotAllowed(t *testing.T) {
 	h := newTestHandler()
 	req := httptest.NewRequest("POST", "/api/v1/ltp", nil)
@@ -96,3 +200,1095 @@ func TestLTP_MethodNotAllowed(t *testing.T) {
 		t.Fatalf("expected 405, got %d", rec.Code)
 	}
 }
+
+func TestOHLC_Success(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/ohlc?pair=btc/usd&interval=1", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Pair     string           `json:"pair"`
+		Interval int              `json:"interval"`
+		OHLC     []map[string]any `json:"ohlc"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body.Pair != "BTC/USD" || body.Interval != 1 || len(body.OHLC) != 2 {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+}
+
+func TestOHLC_BadParams(t *testing.T) {
+	h := newTestHandler()
+	for _, q := range []string{"", "?pair=ETH/USD", "?pair=BTC/USD&interval=7", "?pair=BTC/USD&since=abc"} {
+		req := httptest.NewRequest("GET", "/api/v1/ohlc"+q, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != 400 {
+			t.Fatalf("%q: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestLTP_IncludesLastTradeTime(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		LTP []struct {
+			Pair        string `json:"pair"`
+			LastTradeAt string `json:"last_trade_at"`
+			Stale       bool   `json:"stale"`
+		} `json:"ltp"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if len(body.LTP) != 1 || body.LTP[0].LastTradeAt == "" || body.LTP[0].Stale {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestTrades_Success(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/trades?pair=BTC/EUR", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Pair   string           `json:"pair"`
+		Trades []map[string]any `json:"trades"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body.Pair != "BTC/EUR" || len(body.Trades) != 1 || body.Trades[0]["price"] != 50000.12 {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestTrades_InvalidPair(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/trades?pair=BTC/JPY", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestOrderBook_Success(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/orderbook?pair=BTC/USD&depth=2", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Mid  float64          `json:"mid"`
+		Bids []map[string]any `json:"bids"`
+		Asks []map[string]any `json:"asks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body.Mid != 52000.12 || len(body.Bids) != 2 || body.Asks[1]["cumulative_volume"] != 3.0 {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestOrderBook_InvalidDepth(t *testing.T) {
+	h := newTestHandler()
+	for _, q := range []string{"?pair=BTC/USD&depth=0", "?pair=BTC/USD&depth=501", "?depth=5"} {
+		req := httptest.NewRequest("GET", "/api/v1/orderbook"+q, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != 400 {
+			t.Fatalf("%q: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestSpread_Success(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/spread?pair=BTC/CHF", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body["spread"] != 2.0 || body["pair"] != "BTC/CHF" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestReady_Online(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/ready", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body["ready"] != true || body["upstream"].(map[string]any)["status"] != "online" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestReady_Maintenance(t *testing.T) {
+	svc := service.New(&mockKraken{status: kraken.StatusMaintenance}, time.Minute)
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), svc)
+	req := httptest.NewRequest("GET", "/api/ready", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 503 {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConvert_Success(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/convert?from=BTC&to=EUR&amount=2&precision=3", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if body["result"] != "100000.240" || body["pair"] != "BTC/EUR" || body["rate"] != 50000.12 {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestConvert_NoDirectPair(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("GET", "/api/v1/convert?from=USD&to=CHF&amount=1", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestValuation_Success(t *testing.T) {
+	h := newTestHandler()
+	body := `{"holdings":[{"asset":"BTC","amount":"2","quote":"EUR"},{"asset":"BTC","amount":1,"quote":"USD"}]}`
+	req := httptest.NewRequest("POST", "/api/v1/valuation", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Holdings []map[string]any  `json:"holdings"`
+		Totals   map[string]string `json:"totals"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if len(resp.Holdings) != 2 || resp.Totals["EUR"] != "100000.24" || resp.Totals["USD"] != "52000.12" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestValuation_ItemErrors(t *testing.T) {
+	h := newTestHandler()
+	body := `{"holdings":[{"asset":"BTC","amount":"1","quote":"USD"},{"asset":"ETH","amount":"1","quote":"USD"}]}`
+	req := httptest.NewRequest("POST", "/api/v1/valuation", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var resp struct {
+		Items []struct {
+			Index int `json:"index"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].Index != 1 {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestLTPBatch_Success(t *testing.T) {
+	h := newTestHandler()
+	body := `{"pairs":["BTC/USD",{"pair":"BTC/EUR","precision":0},"DOGE/USD"],"include":["fetched_at","last_trade_at"]}`
+	req := httptest.NewRequest("POST", "/api/v1/ltp:batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	r := resp.Results
+	if len(r) != 3 || r[0]["amount"] != 52000.12 || r[0]["fetched_at"] == nil || r[0]["last_trade_at"] == nil {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+	if r[1]["amount"] != 50000.0 || r[2]["error"] == nil {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestLTPBatch_BadBody(t *testing.T) {
+	h := newTestHandler()
+	req := httptest.NewRequest("POST", "/api/v1/ltp:batch", strings.NewReader(`{"pairs":"BTC/USD"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAlerts_CRUD(t *testing.T) {
+	store, _ := alerts.OpenStore("")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	am := alerts.NewManager(store, alerts.NewDeliverer(alerts.DelivererConfig{}, logger), logger, nil)
+	defer am.Close()
+	h := NewHandler(logger, service.New(&mockKraken{}, time.Minute), WithAlerts(am))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/api/v1/alerts", `{"pair":"BTC/USD","condition":"cross_above","threshold":60000,"webhook_url":"http://example.invalid/hook"}`)
+	if rec.Code != 201 {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created alerts.Alert
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("bad json: %v", err)
+	}
+	if created.ID == "" || created.Secret == "" {
+		t.Fatalf("expected id and generated secret, got %+v", created)
+	}
+
+	rec = do("GET", "/api/v1/alerts/"+created.ID, "")
+	if rec.Code != 200 || strings.Contains(rec.Body.String(), created.Secret) {
+		t.Fatalf("expected 200 without secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = do("PUT", "/api/v1/alerts/"+created.ID, `{"pair":"BTC/USD","condition":"cross_below","threshold":50000,"webhook_url":"http://example.invalid/hook"}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "cross_below") {
+		t.Fatalf("expected updated alert, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = do("GET", "/api/v1/alerts", "")
+	var list struct {
+		Alerts []alerts.Alert `json:"alerts"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Alerts) != 1 || list.Alerts[0].Threshold != 50000 {
+		t.Fatalf("unexpected list: %s", rec.Body.String())
+	}
+	if rec = do("DELETE", "/api/v1/alerts/"+created.ID, ""); rec.Code != 204 {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec = do("GET", "/api/v1/alerts/"+created.ID, ""); rec.Code != 404 {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+	if rec = do("POST", "/api/v1/alerts", `{"pair":"BTC/USD","condition":"cross_above","webhook_url":"http://x"}`); rec.Code != 400 {
+		t.Fatalf("expected 400 for invalid alert, got %d", rec.Code)
+	}
+}
+
+func TestMetrics_Exposed(t *testing.T) {
+	reg := metrics.NewRegistry()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": -1}}
+	h := NewHandler(logger, service.New(mk, time.Minute, service.WithMetrics(reg), service.WithLogger(logger)), WithMetrics(reg))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD", nil))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `price_rejections_total{pair="BTC/USD",reason="non_positive"} 1`) {
+		t.Fatalf("unexpected metrics: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_SavesCacheStateOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	cfg := config.Default()
+	cfg.Cache.StateFile = path
+	srv, err := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := srv.service.LoadState(path); err != nil {
+		t.Fatalf("expected a valid state file, got %v", err)
+	}
+	// e.g. a signal arriving while a deferred Shutdown runs
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("second shutdown: %v", err)
+	}
+}
+
+// notifyWriter signals on ch for every write containing msg.
+type notifyWriter struct {
+	msg string
+	ch  chan struct{}
+}
+
+func (w notifyWriter) Write(b []byte) (int, error) {
+	if strings.Contains(string(b), w.msg) {
+		w.ch <- struct{}{}
+	}
+	return len(b), nil
+}
+
+func TestServer_SavesCacheStatePeriodically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	cfg := config.Default()
+	cfg.Alerts.File = ""
+	cfg.Cache.StateFile = path
+	cfg.Cache.StateInterval = config.Duration(time.Hour)
+	saved := make(chan struct{}, 1)
+	logger := slog.New(slog.NewTextHandler(notifyWriter{"cache state saved", saved}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	srv, err := NewServer(cfg, logger, WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	clk.Advance(time.Hour - time.Nanosecond)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no save before the interval, got %v", err)
+	}
+	clk.Advance(time.Nanosecond)
+	select {
+	case <-saved:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the state saved after the interval")
+	}
+	if _, err := srv.service.LoadState(path); err != nil {
+		t.Fatalf("expected a valid state file, got %v", err)
+	}
+}
+
+func TestServer_ReloadEndpoint(t *testing.T) {
+	t.Cleanup(func() { pairs.SetEnabled(nil) })
+	cfg := config.Default()
+	cfg.Admin.Token = "secret"
+	next := cfg
+	var loadErr error
+	srv, err := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), WithConfigLoader(func() (config.Config, error) { return next, loadErr }))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+	h := srv.server.Handler
+
+	reload := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/admin/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := reload("wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+
+	next.Cache.TTL = config.Duration(time.Minute)
+	next.Pairs.Enabled = []string{"BTC/USD"}
+	next.Port = 9090
+	rec := reload("secret")
+	var body struct {
+		Applied         []config.Change `json:"applied"`
+		RestartRequired []config.Change `json:"restart_required"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != 200 {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(body.Applied) != 2 || len(body.RestartRequired) != 1 || body.RestartRequired[0].Key != "port" {
+		t.Fatalf("unexpected changes: %+v", body)
+	}
+	if srv.service.TTL() != time.Minute || len(pairs.Enabled()) != 1 {
+		t.Fatalf("expected TTL and enabled pairs applied")
+	}
+
+	loadErr = errors.New("cache.ttl: must be positive")
+	if rec := reload("secret"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an invalid config, got %d", rec.Code)
+	}
+	if srv.service.TTL() != time.Minute {
+		t.Fatalf("expected current config kept after a rejected reload")
+	}
+
+	// valid on its own, but with the running 2s Kraken timeout (restart-only)
+	// 3 retries overrun the handler timeout
+	loadErr = nil
+	next.Kraken.Timeout = config.Duration(time.Second)
+	next.Kraken.Retries = 3
+	if rec := reload("secret"); rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "server.handler_timeout") {
+		t.Fatalf("expected 422 for an invalid merged config, got %d %s", rec.Code, rec.Body.String())
+	}
+	if srv.cfg.Kraken.Retries != cfg.Kraken.Retries {
+		t.Fatalf("expected retries kept after a rejected reload, got %d", srv.cfg.Kraken.Retries)
+	}
+}
+
+func TestSnapshotTimeout(t *testing.T) {
+	cfg := config.Default() // 2s Kraken timeout, 2 retries: 6.6s budget
+	if got := snapshotTimeout(cfg); got != cfg.Server.HandlerTimeout.D() {
+		t.Fatalf("expected the handler timeout, got %s", got)
+	}
+	cfg.Server.RouteTimeouts = config.Map[config.Duration]{"/api/v1/ltp": config.Duration(20 * time.Second)}
+	if got := snapshotTimeout(cfg); got != 20*time.Second {
+		t.Fatalf("expected the /api/v1/ltp timeout, got %s", got)
+	}
+	cfg.Server.RouteTimeouts["/api/v1/ltp"] = 0
+	if got, want := snapshotTimeout(cfg), kraken.RetryBudget(2*time.Second, 2); got != want {
+		t.Fatalf("expected the retry budget without a deadline, got %s want %s", got, want)
+	}
+}
+
+func TestServer_LogLevelEndpoint(t *testing.T) {
+	cfg := config.Default()
+	cfg.Admin.Token = "secret"
+	next := cfg
+	level := new(slog.LevelVar)
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level}))
+	srv, err := NewServer(cfg, logger, WithLevelVar(level), WithConfigLoader(func() (config.Config, error) { return next, nil }))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+	h := srv.server.Handler
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do("PUT", "wrong", `{"level":"debug"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	if rec := do("PUT", "secret", `{"level":"loud"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown level, got %d", rec.Code)
+	}
+	if rec := do("PUT", "secret", `{"level":"debug"}`); rec.Code != 200 || !strings.Contains(rec.Body.String(), `"level":"debug"`) {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	if level.Level() != slog.LevelDebug || !strings.Contains(logs.String(), "log level changed") {
+		t.Fatalf("expected the level changed and logged, got %s", level.Level())
+	}
+
+	// a reload keeps the runtime level unless log.level itself changed
+	next.Cache.TTL = config.Duration(time.Minute)
+	if _, err := srv.Reload(); err != nil || level.Level() != slog.LevelDebug {
+		t.Fatalf("expected the runtime level kept, got %s err=%v", level.Level(), err)
+	}
+	next.Log.Level = "warn"
+	if _, err := srv.Reload(); err != nil || level.Level() != slog.LevelWarn {
+		t.Fatalf("expected the configured level applied, got %s err=%v", level.Level(), err)
+	}
+	if rec := do("GET", "secret", ""); rec.Code != 200 || !strings.Contains(rec.Body.String(), `"level":"warn"`) {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAccessLog_SamplingAndRedaction(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}, status: kraken.StatusOnline}
+	var logs strings.Builder
+	h := NewHandler(slog.New(slog.NewTextHandler(&logs, nil)), service.New(mk, time.Minute),
+		WithAccessLog(map[string]float64{"/api/v1/ltp": 0.25, "/api/v1/ohlc": 0, "/api/ready": 1}))
+	get := func(path string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	count := func(path string) int {
+		return strings.Count(logs.String(), "path="+path+" ")
+	}
+
+	for range 8 {
+		get("/api/v1/ltp?pairs=BTC/USD")
+		get("/api/health")
+		get("/api/v1/ohlc?pair=BTC/USD&interval=1")
+	}
+	get("/api/ready")
+	get("/api/v1/spread?pair=BTC/USD")
+	if n := count("/api/v1/ltp"); n != 2 {
+		t.Fatalf("expected a quarter of ltp requests logged, got %d", n)
+	}
+	if count("/api/health") != 0 || count("/api/v1/ohlc") != 0 {
+		t.Fatalf("expected health and ohlc suppressed, got:\n%s", logs.String())
+	}
+	if count("/api/ready") != 1 || count("/api/v1/spread") != 1 {
+		t.Fatalf("expected ready (listed) and spread (not listed) logged, got:\n%s", logs.String())
+	}
+	// failures are always logged
+	get("/api/v1/ohlc?pair=DOGE/USD&interval=1")
+	if count("/api/v1/ohlc") != 1 {
+		t.Fatalf("expected a failed suppressed request logged, got:\n%s", logs.String())
+	}
+
+	get("/api/v1/spread?pair=BTC/USD&api_key=s3cret&Token=abc")
+	if strings.Contains(logs.String(), "s3cret") || strings.Contains(logs.String(), "abc") ||
+		!strings.Contains(logs.String(), "pair=BTC/USD&api_key=REDACTED&Token=REDACTED") {
+		t.Fatalf("expected secrets redacted in the query, got:\n%s", logs.String())
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	})
+
+	start := time.Now()
+	withDeadline(3*time.Second, h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !hasDeadline || deadline.Before(start.Add(3*time.Second)) || deadline.After(time.Now().Add(3*time.Second)) {
+		t.Fatalf("expected a 3s deadline, got %v (set=%v)", deadline, hasDeadline)
+	}
+
+	withDeadline(0, h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if hasDeadline {
+		t.Fatalf("expected no deadline for a streaming route")
+	}
+}
+
+func TestWithDeadline_LiftsWriteTimeout(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := httptest.NewUnstartedServer(withLogging(slog.New(slog.NewTextHandler(io.Discard, nil)), 1, withDeadline(0, h)))
+	// the write deadline has passed by the time any handler writes
+	srv.Config.WriteTimeout = time.Nanosecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the write timeout lifted, got %v", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != "ok" {
+		t.Fatalf("unexpected body %q", b)
+	}
+}
+
+func TestNewServer_InvalidCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.TLS.CertFile = filepath.Join(dir, "tls.crt")
+	cfg.TLS.KeyFile = filepath.Join(dir, "tls.key")
+	os.WriteFile(cfg.TLS.CertFile, []byte("not a certificate"), 0o600)
+	os.WriteFile(cfg.TLS.KeyFile, []byte("not a key"), 0o600)
+	if _, err := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatalf("expected an error for an invalid certificate")
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 into dir and
+// returns the cert and key paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewServer_StopsWhatItStartedOnError(t *testing.T) {
+	cfg := config.Default()
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = writeTestCert(t, t.TempDir())
+	// fails once the certificate reloader is already watching its files
+	cfg.Tracing.Exporter = "bogus"
+
+	before := runtime.NumGoroutine()
+	if _, err := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatalf("expected an error for an unknown tracing exporter")
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d before, %d after", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// freePort returns a TCP port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestServer_StartServesHTTPS(t *testing.T) {
+	for _, separate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("separate=%v", separate), func(t *testing.T) {
+			cfg := config.Default()
+			cfg.TLS.CertFile, cfg.TLS.KeyFile = writeTestCert(t, t.TempDir())
+			cfg.Port = freePort(t)
+			httpsPort := cfg.Port
+			if separate {
+				cfg.TLS.Port = freePort(t)
+				httpsPort = cfg.TLS.Port
+			}
+			srv, err := NewServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			errc := make(chan error, 1)
+			go func() { errc <- srv.Start() }()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+			defer client.CloseIdleConnections()
+			get := func(url string) (*http.Response, error) {
+				// the listeners may not be up yet
+				for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+					resp, err := client.Get(url)
+					if err == nil || time.Now().After(deadline) {
+						return resp, err
+					}
+					select {
+					case err := <-errc:
+						t.Fatalf("server stopped: %v", err)
+					default:
+					}
+				}
+			}
+			resp, err := get(fmt.Sprintf("https://127.0.0.1:%d/api/health", httpsPort))
+			if err != nil {
+				t.Fatalf("https: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+				t.Fatalf("unexpected https response: %d, tls %v", resp.StatusCode, resp.TLS != nil)
+			}
+			if separate {
+				resp, err := get(fmt.Sprintf("http://127.0.0.1:%d/api/health", cfg.Port))
+				if err != nil {
+					t.Fatalf("http: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("unexpected http response: %d", resp.StatusCode)
+				}
+			}
+
+			if err := srv.Shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown: %v", err)
+			}
+			select {
+			case err := <-errc:
+				if !errors.Is(err, http.ErrServerClosed) {
+					t.Fatalf("expected http.ErrServerClosed from Start, got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Start did not return after Shutdown")
+			}
+		})
+	}
+}
+
+func TestAuth_KeysScopesAndQuotas(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}, status: kraken.StatusOnline}
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	auth := NewAuthenticator([]APIKey{
+		{ID: "reader", Digest: sha256.Sum256([]byte("r-secret")), Scopes: []string{"/api/v1/ltp", "/api/v1/alerts*"}, Quota: 2, QuotaWindow: time.Hour},
+		{ID: "ops", Digest: sha256.Sum256([]byte("o-secret"))},
+	}, nil)
+	store, _ := alerts.OpenStore("")
+	am := alerts.NewManager(store, alerts.NewDeliverer(alerts.DelivererConfig{}, logger), logger, nil)
+	defer am.Close()
+	h := NewHandler(logger, service.New(mk, time.Minute), WithAuth(auth), WithAlerts(am), WithMetrics(metrics.NewRegistry()))
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, probe := range []string{"/api/health", "/api/ready", "/metrics"} {
+		if rec := do(probe); rec.Code != 200 {
+			t.Fatalf("expected %s to be exempt, got %d", probe, rec.Code)
+		}
+	}
+	rec := do("/api/v1/ltp?pairs=BTC/USD")
+	if rec.Code != 401 || rec.Header().Get("WWW-Authenticate") == "" || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Fatalf("expected 401 without a key, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("/api/v1/ltp?pairs=BTC/USD", "Authorization", "Bearer wrong"); rec.Code != 401 {
+		t.Fatalf("expected 401 for an unknown key, got %d", rec.Code)
+	}
+	if rec := do("/api/v1/ohlc?pair=BTC/USD&interval=1", "X-API-Key", "r-secret"); rec.Code != 403 {
+		t.Fatalf("expected 403 outside the key's scopes, got %d", rec.Code)
+	}
+	if rec := do("/api/v1/alerts/unknown", "X-API-Key", "r-secret"); rec.Code != 404 {
+		t.Fatalf("expected the prefix scope to allow alerts, got %d", rec.Code)
+	}
+	if rec := do("/api/v1/ltp?pairs=BTC/USD", "X-API-Key", "r-secret"); rec.Code != 200 {
+		t.Fatalf("expected 200 within quota, got %d", rec.Code)
+	}
+	rec = do("/api/v1/ltp?pairs=BTC/USD", "X-API-Key", "r-secret")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 once the quota is used up, got %d", rec.Code)
+	}
+	if rec := do("/api/v1/ohlc?pair=BTC/USD&interval=1", "Authorization", "Bearer o-secret"); rec.Code != 200 {
+		t.Fatalf("expected a key without scopes or quota to pass, got %d", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "key=reader") || !strings.Contains(logs.String(), "key=ops") {
+		t.Fatalf("expected key IDs in the request log, got:\n%s", logs.String())
+	}
+
+	// revoking every key turns authentication off, like starting without keys
+	auth.SetKeys(nil)
+	if rec := do("/api/v1/ltp?pairs=BTC/USD"); rec.Code != 200 {
+		t.Fatalf("expected open access without keys, got %d", rec.Code)
+	}
+}
+
+func TestAuth_QuotaWindowResets(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	a := NewAuthenticator(nil, clk)
+	k := &APIKey{ID: "k", Quota: 1, QuotaWindow: time.Minute}
+	if ok, _ := a.allow(k); !ok {
+		t.Fatalf("expected first request allowed")
+	}
+	if ok, wait := a.allow(k); ok || wait != time.Minute {
+		t.Fatalf("expected second request denied for a minute, got ok=%v wait=%s", ok, wait)
+	}
+	clk.Advance(time.Minute)
+	if ok, _ := a.allow(k); !ok {
+		t.Fatalf("expected a new window to allow requests")
+	}
+}
+
+func TestRateLimit_ConcurrentClients(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}, status: kraken.StatusOnline}
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	limiter := NewRateLimiter(RateLimits{
+		Default: RateLimit{Rate: 1, Burst: 5},
+		Routes:  map[string]RateLimit{"/api/v1/ohlc": {Rate: 0}},
+	}, 100, clk)
+	defer limiter.Close()
+	auth := NewAuthenticator(nil, clk)
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), service.New(mk, time.Minute), WithAuth(auth), WithRateLimit(limiter))
+
+	do := func(path, ip, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// ten clients hammer the same route at once; each gets exactly its burst.
+	// Without API keys every client is its IP.
+	const clients, perClient = 10, 20
+	var wg sync.WaitGroup
+	var allowed [clients]atomic.Int32
+	for c := range clients {
+		for range perClient {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if rec := do("/api/v1/ltp?pairs=BTC/USD", fmt.Sprintf("10.0.0.%d", c), ""); rec.Code == 200 {
+					allowed[c].Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	for c := range clients {
+		if n := allowed[c].Load(); n != 5 {
+			t.Fatalf("client %d: expected 5 requests allowed, got %d", c, n)
+		}
+	}
+
+	rec := do("/api/v1/ltp?pairs=BTC/USD", "10.0.0.0", "")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" ||
+		rec.Header().Get("RateLimit-Limit") != "5" || rec.Header().Get("RateLimit-Reset") != "5" {
+		t.Fatalf("expected 429 with rate limit headers, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := do("/api/v1/ohlc?pair=BTC/USD&interval=1", "10.0.0.0", ""); rec.Code != 200 || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("expected a route without limit to pass, got %d", rec.Code)
+	}
+	clk.Advance(2 * time.Second)
+	rec = do("/api/v1/ltp?pairs=BTC/USD", "10.0.0.0", "")
+	if rec.Code != 200 || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("expected refilled tokens, got %d remaining=%s", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+
+	// with a key the bucket follows the key, not the address
+	auth.SetKeys([]APIKey{{ID: "shared", Digest: sha256.Sum256([]byte("s-secret"))}})
+	for i := range 5 {
+		if rec := do("/api/v1/ltp?pairs=BTC/USD", fmt.Sprintf("10.1.0.%d", i), "s-secret"); rec.Code != 200 {
+			t.Fatalf("request %d: expected 200 within the key's burst, got %d", i, rec.Code)
+		}
+	}
+	if rec := do("/api/v1/ltp?pairs=BTC/USD", "10.1.0.9", "s-secret"); rec.Code != 429 {
+		t.Fatalf("expected the key's bucket to be shared across addresses, got %d", rec.Code)
+	}
+
+	// lifting the limit at runtime lets everyone through
+	limiter.SetLimits(RateLimits{})
+	if rec := do("/api/v1/ltp?pairs=BTC/USD", "10.1.0.9", "s-secret"); rec.Code != 200 {
+		t.Fatalf("expected no limit after SetLimits, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_BoundsTrackedClients(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	l := NewRateLimiter(RateLimits{}, 3, clk)
+	defer l.Close()
+	rl := RateLimit{Rate: 1, Burst: 1}
+	for i := range 10 {
+		if ok, _, _, _ := l.take(rl, "/r", fmt.Sprint(i)); !ok {
+			t.Fatalf("client %d: expected first request allowed", i)
+		}
+	}
+	if n := l.buckets.Len(); n != 3 {
+		t.Fatalf("expected 3 tracked clients, got %d", n)
+	}
+	// an evicted client starts over with a full bucket
+	if ok, _, _, _ := l.take(rl, "/r", "0"); !ok {
+		t.Fatalf("expected an evicted client to get a new bucket")
+	}
+	if ok, _, _, retry := l.take(rl, "/r", "9"); ok || retry != time.Second {
+		t.Fatalf("expected a tracked client to be limited for 1s, got ok=%v retry=%s", ok, retry)
+	}
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	p, err := ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	cases := []struct {
+		name, remote string
+		header       map[string]string
+		want         string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"spoofed XFF from untrusted peer", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"spoofed X-Real-IP from untrusted peer", "203.0.113.7:5000", map[string]string{"X-Real-IP": "1.2.3.4"}, "203.0.113.7"},
+		{"proxied", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"client prepends a fake hop", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7"}, "203.0.113.7"},
+		{"proxy chain", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.1.1.1"}, "203.0.113.7"},
+		{"client claims to be a trusted proxy", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "10.9.9.9, 203.0.113.7"}, "203.0.113.7"},
+		{"garbage hop", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "203.0.113.7, bogus, 10.1.1.1"}, "10.1.1.1"},
+		{"only trusted hops", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "10.1.1.1"}, "10.1.1.1"},
+		{"forwarded", "10.0.0.2:5000", map[string]string{"Forwarded": `for=1.2.3.4, for="203.0.113.7:4711";proto=https`}, "203.0.113.7"},
+		{"forwarded IPv6", "[2001:db8::1]:443", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"forwarded obfuscated", "10.0.0.2:5000", map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.2"},
+		{"forwarded preferred over XFF", "10.0.0.2:5000", map[string]string{"Forwarded": "for=203.0.113.7", "X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"x-real-ip", "10.0.0.2:5000", map[string]string{"X-Real-IP": "203.0.113.7"}, "203.0.113.7"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.2]:5000", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		if got := p.ClientIP(req); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("expected error for an invalid CIDR")
+	}
+}
+
+func TestRateLimit_IgnoresSpoofedForwardedFor(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}, status: kraken.StatusOnline}
+	var logs strings.Builder
+	limiter := NewRateLimiter(RateLimits{Default: RateLimit{Rate: 1, Burst: 1}}, 100, clocktest.NewFake(time.Unix(0, 0)))
+	defer limiter.Close()
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	h := NewHandler(slog.New(slog.NewTextHandler(&logs, nil)), service.New(mk, time.Minute), WithRateLimit(limiter), WithTrustedProxies(proxies))
+
+	do := func(remote, xff string) int {
+		req := httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// a direct client cannot get a fresh bucket by inventing addresses
+	if code := do("203.0.113.7:5000", "1.1.1.1"); code != 200 {
+		t.Fatalf("expected first request allowed, got %d", code)
+	}
+	if code := do("203.0.113.7:5000", "2.2.2.2"); code != 429 {
+		t.Fatalf("expected spoofed address to share the peer's bucket, got %d", code)
+	}
+	// behind the proxy clients are told apart by the forwarded address
+	if code := do("10.0.0.2:5000", "198.51.100.1"); code != 200 {
+		t.Fatalf("expected proxied client allowed, got %d", code)
+	}
+	if code := do("10.0.0.2:5000", "198.51.100.2"); code != 200 {
+		t.Fatalf("expected another proxied client allowed, got %d", code)
+	}
+	if !strings.Contains(logs.String(), "ip=198.51.100.2") || strings.Contains(logs.String(), "ip=2.2.2.2") {
+		t.Fatalf("expected resolved addresses in the request log, got:\n%s", logs.String())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var logs strings.Builder
+	logger := slog.New(requestid.NewHandler(slog.NewTextHandler(&logs, nil)))
+	mk := &mockKraken{err: errors.New("boom"), status: kraken.StatusOnline}
+	h := NewHandler(logger, service.New(mk, time.Minute))
+
+	do := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD", nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("client-42")
+	if rec.Code != 502 || rec.Header().Get("X-Request-ID") != "client-42" {
+		t.Fatalf("expected the client's ID echoed, got %d %q", rec.Code, rec.Header().Get("X-Request-ID"))
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["request_id"] != "client-42" {
+		t.Fatalf("expected request_id in the error body, got %s", rec.Body.String())
+	}
+	// the access log line and the handler's error line are correlated
+	for _, msg := range []string{"msg=http", `msg="ltp fetch failed"`} {
+		found := false
+		for _, line := range strings.Split(logs.String(), "\n") {
+			found = found || (strings.Contains(line, msg) && strings.Contains(line, "request_id=client-42"))
+		}
+		if !found {
+			t.Fatalf("expected %s logged with the request ID, got:\n%s", msg, logs.String())
+		}
+	}
+
+	for _, sent := range []string{"", "bad id\r\nX-Injected: 1"} {
+		id := do(sent).Header().Get("X-Request-ID")
+		if len(id) != 32 || id == sent {
+			t.Fatalf("expected a generated ID for %q, got %q", sent, id)
+		}
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/health", nil))
+	if rec.Header().Get("X-Request-ID") == "" || rec.Body.String() != "ok" {
+		t.Fatalf("expected an ID on every route, got %v", rec.Header())
+	}
+}
+
+func TestTracing_RequestToKraken(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	var logs strings.Builder
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}, status: kraken.StatusOnline}
+	svc := service.New(mk, time.Minute, service.WithTracerProvider(tp))
+	h := NewHandler(slog.New(slog.NewTextHandler(&logs, nil)), svc, WithTracing(tp))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for range 2 {
+		req := httptest.NewRequest("GET", "/api/v1/ltp?pairs=BTC/USD", nil)
+		req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected a server and a service span per request, got %d", len(spans))
+	}
+	for i, hits := range []int64{0, 1} {
+		lookup, server := spans[2*i], spans[2*i+1]
+		if server.Name() != "GET /api/v1/ltp" || server.SpanKind() != trace.SpanKindServer ||
+			server.SpanContext().TraceID().String() != traceID || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+			t.Fatalf("expected a server span continuing the caller's trace, got %s in %s", server.Name(), server.SpanContext().TraceID())
+		}
+		if lookup.Name() != "Service.GetLTP" || lookup.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Fatalf("expected the service span under the server span, got %s", lookup.Name())
+		}
+		got := map[attribute.Key]int64{}
+		for _, kv := range lookup.Attributes() {
+			got[kv.Key] = kv.Value.AsInt64()
+		}
+		if got["cache.hits"] != hits || got["cache.misses"] != 1-hits {
+			t.Fatalf("request %d: expected %d cache hits, got %v", i, hits, got)
+		}
+	}
+	if !strings.Contains(logs.String(), "trace_id="+traceID) {
+		t.Fatalf("expected the trace ID in the request log, got:\n%s", logs.String())
+	}
+}
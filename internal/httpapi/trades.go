@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitcoin-prices/internal/service"
+)
+
+// tradesHandler serves GET /api/v1/trades?pair=&since=.
+// since is unix seconds (optional); only trades after it are returned.
+func tradesHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		pair, err := service.ParsePairQuery(q.Get("pair"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		var since time.Time
+		if v := q.Get("since"); v != "" {
+			sec, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || sec < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid since: " + v})
+				return
+			}
+			since = time.Unix(sec, 0)
+		}
+		ctx := r.Context()
+
+		trades, err := svc.GetTrades(ctx, pair, since)
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch trades")
+			logger.ErrorContext(ctx, "trades fetch failed", "err", err, "pair", pair)
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildTradesResponse(pair, trades))
+	}
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"bitcoin-prices/internal/service"
+)
+
+// maxBodyBytes bounds JSON request bodies.
+const maxBodyBytes = 1 << 20
+
+// valuationHandler serves POST /api/v1/valuation.
+// Invalid line items are reported individually with 400; nothing is valued then.
+// Pairs without a price from the shared fetch are reported by pair with 502.
+func valuationHandler(logger *slog.Logger, svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		holdings, itemErrs, err := service.ParseHoldings(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		if len(itemErrs) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid holdings", "items": itemErrs})
+			return
+		}
+		ctx := r.Context()
+
+		v, err := svc.Value(ctx, holdings)
+		var priceErrs service.PriceErrors
+		if errors.As(err, &priceErrs) {
+			msgs := make(map[string]string, len(priceErrs))
+			for p, err := range priceErrs {
+				msgs[p] = err.Error()
+			}
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": "failed to fetch prices", "pairs": msgs})
+			logger.ErrorContext(ctx, "valuation failed", "err", err, "holdings", len(holdings))
+			return
+		}
+		if err != nil {
+			writeUpstreamError(w, err, "failed to fetch prices")
+			logger.ErrorContext(ctx, "valuation failed", "err", err, "holdings", len(holdings))
+			return
+		}
+		writeJSON(w, http.StatusOK, service.BuildValuationResponse(v))
+	}
+}
@@ -0,0 +1,137 @@
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxDepth is the largest order book depth Kraken serves per side.
+const MaxDepth = 500
+
+// Level is a single order book price level.
+type Level struct {
+	Price  float64
+	Volume float64
+	Time   time.Time
+}
+
+// OrderBook is the result of GetDepth. Bids are ordered best (highest) first,
+// asks best (lowest) first.
+type OrderBook struct {
+	Bids []Level `json:"bids"`
+	Asks []Level `json:"asks"`
+}
+
+// SpreadPoint is a single top-of-book observation.
+type SpreadPoint struct {
+	Time time.Time
+	Bid  float64
+	Ask  float64
+}
+
+// Spreads is the result of GetSpread. Points are ordered by time ascending.
+// Last is the id to pass as since to poll for newer data.
+type Spreads struct {
+	Points []SpreadPoint
+	Last   int64
+}
+
+// GetDepth returns the order book for a Kraken pair code, up to count levels
+// per side (1..MaxDepth).
+func (c *Client) GetDepth(ctx context.Context, krakenPair string, count int) (*OrderBook, error) {
+	if count < 1 || count > MaxDepth {
+		return nil, fmt.Errorf("unsupported depth: %d", count)
+	}
+	q := url.Values{}
+	q.Set("pair", krakenPair)
+	q.Set("count", strconv.Itoa(count))
+
+	var result map[string]OrderBook
+	if err := c.getPublic(ctx, "Depth", q, &result); err != nil {
+		return nil, err
+	}
+	for _, ob := range result {
+		return &ob, nil
+	}
+	return nil, fmt.Errorf("no order book for %s", krakenPair)
+}
+
+// GetSpread returns recent top-of-book spreads for a Kraken pair code.
+// since (unix seconds) is optional.
+func (c *Client) GetSpread(ctx context.Context, krakenPair string, since int64) (*Spreads, error) {
+	q := url.Values{}
+	q.Set("pair", krakenPair)
+	if since > 0 {
+		q.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	var result map[string]json.RawMessage
+	if err := c.getPublic(ctx, "Spread", q, &result); err != nil {
+		return nil, err
+	}
+	out := &Spreads{}
+	for key, raw := range result {
+		if key == "last" {
+			if err := json.Unmarshal(raw, &out.Last); err != nil {
+				return nil, fmt.Errorf("parse last: %w", err)
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, &out.Points); err != nil {
+			return nil, fmt.Errorf("parse spread for %s: %w", key, err)
+		}
+	}
+	return out, nil
+}
+
+// UnmarshalJSON decodes Kraken's mixed-type book row: ["price", "volume", timestamp].
+func (l *Level) UnmarshalJSON(b []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(b, &row); err != nil {
+		return err
+	}
+	if len(row) < 3 {
+		return fmt.Errorf("book row has %d fields, want 3", len(row))
+	}
+	var err error
+	if l.Price, err = parseStringFloat(row[0]); err != nil {
+		return fmt.Errorf("book price: %w", err)
+	}
+	if l.Volume, err = parseStringFloat(row[1]); err != nil {
+		return fmt.Errorf("book volume: %w", err)
+	}
+	var ts int64
+	if err := json.Unmarshal(row[2], &ts); err != nil {
+		return fmt.Errorf("book time: %w", err)
+	}
+	l.Time = time.Unix(ts, 0).UTC()
+	return nil
+}
+
+// UnmarshalJSON decodes Kraken's mixed-type spread row: [time, "bid", "ask"].
+func (p *SpreadPoint) UnmarshalJSON(b []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(b, &row); err != nil {
+		return err
+	}
+	if len(row) < 3 {
+		return fmt.Errorf("spread row has %d fields, want 3", len(row))
+	}
+	var ts int64
+	if err := json.Unmarshal(row[0], &ts); err != nil {
+		return fmt.Errorf("spread time: %w", err)
+	}
+	p.Time = time.Unix(ts, 0).UTC()
+	var err error
+	if p.Bid, err = parseStringFloat(row[1]); err != nil {
+		return fmt.Errorf("spread bid: %w", err)
+	}
+	if p.Ask, err = parseStringFloat(row[2]); err != nil {
+		return fmt.Errorf("spread ask: %w", err)
+	}
+	return nil
+}
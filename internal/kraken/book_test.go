@@ -0,0 +1,55 @@
+package kraken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_GetDepth_DecodesLevels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/Depth" || r.URL.Query().Get("count") != "2" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{
+			"asks":[["52001.0","1.5",1700000000],["52002.0","2.0",1700000001]],
+			"bids":[["51999.0","0.5",1700000002],["51998.0","3.0",1700000003]]
+		}}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	ob, err := c.GetDepth(context.Background(), "XXBTZUSD", 2)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(ob.Asks) != 2 || len(ob.Bids) != 2 {
+		t.Fatalf("expected 2 levels per side, got %+v", ob)
+	}
+	if ob.Asks[0].Price != 52001 || ob.Asks[0].Volume != 1.5 || ob.Bids[1].Price != 51998 || ob.Bids[1].Time.Unix() != 1700000003 {
+		t.Fatalf("unexpected levels: %+v", ob)
+	}
+	if _, err := c.GetDepth(context.Background(), "XXBTZUSD", MaxDepth+1); err == nil {
+		t.Fatalf("expected error for excessive depth")
+	}
+}
+
+func TestClient_GetSpread_DecodesRows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":[[1700000000,"51999.0","52001.0"],[1700000005,"52000.0","52000.5"]],"last":1700000005}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	res, err := c.GetSpread(context.Background(), "XXBTZUSD", 0)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Last != 1700000005 || len(res.Points) != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if p := res.Points[1]; p.Bid != 52000 || p.Ask != 52000.5 || p.Time.Unix() != 1700000005 {
+		t.Fatalf("unexpected point: %+v", p)
+	}
+}
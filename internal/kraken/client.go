@@ -10,19 +10,49 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"bitcoin-prices/internal/clock"
+	"bitcoin-prices/internal/requestid"
+	"bitcoin-prices/internal/tracing"
 )
 
+// ErrUnknownPair is returned when Kraken does not recognise a requested pair.
+var ErrUnknownPair = errors.New("kraken: unknown asset pair")
+
 // Client fetches ticker info from Kraken public API.
 // It supports batching multiple pairs in one request.
 // Zero-value is not valid; use NewClient.
 type Client struct {
 	baseURL string
 	http    *http.Client
-	retries int
+	retries atomic.Int32
+	clock   clock.Clock
+	tracer  trace.Tracer
+}
+
+// ClientOption configures optional Client behaviour.
+type ClientOption func(*Client)
+
+// WithClock sets the clock used for retry backoff (default clock.Real).
+func WithClock(clk clock.Clock) ClientOption {
+	return func(c *Client) { c.clock = clk }
 }
 
-func NewClient(baseURL string, httpClient *http.Client, retries int) *Client {
+// WithTracerProvider records a span per call and per attempt with tp
+// (default the global provider).
+func WithTracerProvider(tp trace.TracerProvider) ClientOption {
+	return func(c *Client) { c.tracer = tp.Tracer("bitcoin-prices/internal/kraken") }
+}
+
+func NewClient(baseURL string, httpClient *http.Client, retries int, opts ...ClientOption) *Client {
 	if baseURL == "" {
 		baseURL = "https://api.kraken.com"
 	}
@@ -32,7 +62,19 @@ func NewClient(baseURL string, httpClient *http.Client, retries int) *Client {
 	if retries < 0 {
 		retries = 0
 	}
-	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient, retries: retries}
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient, clock: clock.Real,
+		tracer: otel.GetTracerProvider().Tracer("bitcoin-prices/internal/kraken")}
+	c.retries.Store(int32(retries))
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetRetries changes how often requests are retried on 429/5xx and network
+// errors. It applies to requests started afterwards.
+func (c *Client) SetRetries(n int) {
+	c.retries.Store(int32(max(n, 0)))
 }
 
 // GetLastTradeClosed returns the last trade closed price for each Kraken pair code provided.
@@ -51,67 +93,144 @@ func (c *Client) GetLastTradeClosed(ctx context.Context, krakenPairs []string) (
 		}
 	}
 
-	endpoint := c.baseURL + "/0/public/Ticker"
 	q := url.Values{}
 	q.Set("pair", strings.Join(uniq, ","))
-	u := endpoint + "?" + q.Encode()
 
-	var lastErr error
-	for attempt := 0; attempt <= c.retries; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-		if err != nil {
-			return nil, err
+	var result map[string]tickerResult
+	if err := c.getPublic(ctx, "Ticker", q, &result); err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(result))
+	for pair, data := range result {
+		if len(data.C) >= 1 {
+			priceStr := data.C[0]
+			f, err := strconv.ParseFloat(priceStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse price %s for %s: %w", priceStr, pair, err)
+			}
+			out[pair] = f
 		}
-		resp, err := c.http.Do(req)
+	}
+	return out, nil
+}
+
+// getPublic calls a Kraken public endpoint (e.g. "Ticker") and decodes the
+// "result" field of the response envelope into out.
+// It retries with backoff on 429/5xx and network errors.
+func (c *Client) getPublic(ctx context.Context, method string, q url.Values, out any) (err error) {
+	u := c.baseURL + "/0/public/" + method
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	ctx, span := c.tracer.Start(ctx, "kraken."+method)
+	defer func() {
 		if err != nil {
-			lastErr = err
-		} else {
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
-				lastErr = fmt.Errorf("kraken http %d", resp.StatusCode)
-			} else if resp.StatusCode != http.StatusOK {
-				b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-				return nil, fmt.Errorf("kraken http %d: %s", resp.StatusCode, string(b))
-			} else {
-				var tk tickerResponse
-				dec := json.NewDecoder(resp.Body)
-				if err := dec.Decode(&tk); err != nil {
-					return nil, err
-				}
-				if len(tk.Error) > 0 {
-					return nil, errors.New(strings.Join(tk.Error, "; "))
-				}
-				out := make(map[string]float64, len(tk.Result))
-				for pair, data := range tk.Result {
-					if len(data.C) >= 1 {
-						priceStr := data.C[0]
-						f, err := strconv.ParseFloat(priceStr, 64)
-						if err != nil {
-							return nil, fmt.Errorf("parse price %s for %s: %w", priceStr, pair, err)
-						}
-						out[pair] = f
-					}
-				}
-				return out, nil
-			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var lastErr error
+	retries := int(c.retries.Load())
+	for attempt := 0; attempt <= retries; attempt++ {
+		retry, err := c.doPublic(ctx, u, attempt, out)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return err
 		}
+		lastErr = err
 		// Retry with backoff for 429/5xx or network errors
-		if attempt < c.retries {
+		if attempt < retries {
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(time.Duration(200*(1<<attempt)) * time.Millisecond):
+				return ctx.Err()
+			case <-c.clock.After(backoff(attempt)):
 			}
 		}
 	}
-	return nil, lastErr
+	return lastErr
+}
+
+// backoff is the wait after the given (0-based) failed attempt.
+func backoff(attempt int) time.Duration {
+	return time.Duration(200*(1<<attempt)) * time.Millisecond
+}
+
+// RetryBudget is the longest a request can take with an HTTP client timeout
+// of timeout and the given number of retries: every attempt times out and
+// is followed by its backoff.
+func RetryBudget(timeout time.Duration, retries int) time.Duration {
+	d := time.Duration(retries+1) * timeout
+	for attempt := 0; attempt < retries; attempt++ {
+		d += backoff(attempt)
+	}
+	return d
+}
+
+// doPublic performs a single attempt, traced as an HTTP client span that is
+// propagated to Kraken. It reports whether the error is retryable.
+func (c *Client) doPublic(ctx context.Context, u string, attempt int, out any) (retry bool, err error) {
+	ctx, span := c.tracer.Start(ctx, http.MethodGet, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", http.MethodGet), attribute.String("url.full", u)))
+	if attempt > 0 {
+		span.SetAttributes(attribute.Int("http.request.resend_count", attempt))
+	}
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	span.SetAttributes(attribute.String("server.address", req.URL.Hostname()))
+	// lets the egress proxy logs be matched to our request logs
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	tracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return true, fmt.Errorf("kraken http %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return false, fmt.Errorf("kraken http %d: %s", resp.StatusCode, string(b))
+	}
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return false, err
+	}
+	if len(env.Error) > 0 {
+		msg := strings.Join(env.Error, "; ")
+		if strings.Contains(msg, "Unknown asset pair") {
+			return false, fmt.Errorf("%w: %s", ErrUnknownPair, msg)
+		}
+		return false, errors.New(msg)
+	}
+	if out == nil || len(env.Result) == 0 {
+		return false, nil
+	}
+	return false, json.Unmarshal(env.Result, out)
 }
 
 // Minimal structs matching Kraken response
 
-type tickerResponse struct {
-	Error  []string                `json:"error"`
-	Result map[string]tickerResult `json:"result"`
+type envelope struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
 }
 
 type tickerResult struct {
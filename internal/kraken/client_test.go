@@ -0,0 +1,165 @@
+package kraken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/requestid"
+)
+
+func TestClient_RetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"c":["52000.1","0.1"]}}}`))
+	}))
+	defer srv.Close()
+
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := NewClient(srv.URL, srv.Client(), 2, WithClock(clk))
+
+	type result struct {
+		prices map[string]float64
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		prices, err := c.GetLastTradeClosed(context.Background(), []string{"XXBTZUSD"})
+		done <- result{prices, err}
+	}()
+
+	// backoff doubles: 200ms, then 400ms
+	for _, d := range []time.Duration{200 * time.Millisecond, 400 * time.Millisecond} {
+		clk.BlockUntil(1)
+		clk.Advance(d - time.Nanosecond)
+		select {
+		case <-done:
+			t.Fatalf("retried before the backoff elapsed")
+		default:
+		}
+		clk.Advance(time.Nanosecond)
+	}
+	res := <-done
+	if res.err != nil || res.prices["XXBTZUSD"] != 52000.1 {
+		t.Fatalf("unexpected result %v err=%v", res.prices, res.err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestClient_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 2, WithClock(clocktest.NewFake(time.Unix(0, 0))))
+	if _, err := c.GetLastTradeClosed(context.Background(), []string{"XXBTZUSD"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 attempt, got %d", n)
+	}
+}
+
+func TestClient_ForwardsRequestID(t *testing.T) {
+	var got atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("X-Request-ID"))
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"c":["52000.1","0.1"]}}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	if _, err := c.GetLastTradeClosed(ctx, []string{"XXBTZUSD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got.Load() != "req-1" {
+		t.Fatalf("expected request ID forwarded, got %q", got.Load())
+	}
+}
+
+func TestClient_TracesAttempts(t *testing.T) {
+	var calls atomic.Int32
+	var traceparent atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent.Store(r.Header.Get("Traceparent"))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"c":["52000.1","0.1"]}}}`))
+	}))
+	defer srv.Close()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	c := NewClient(srv.URL, srv.Client(), 1, WithClock(clk), WithTracerProvider(tp))
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetLastTradeClosed(context.Background(), []string{"XXBTZUSD"})
+		done <- err
+	}()
+	clk.BlockUntil(1)
+	clk.Advance(200 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 2 attempt spans and a call span, got %d", len(spans))
+	}
+	first, second, call := spans[0], spans[1], spans[2]
+	if call.Name() != "kraken.Ticker" || first.Parent().SpanID() != call.SpanContext().SpanID() || second.Parent().SpanID() != call.SpanContext().SpanID() {
+		t.Fatalf("expected attempts under the call span, got %s, %s, %s", first.Name(), second.Name(), call.Name())
+	}
+	if first.SpanKind() != trace.SpanKindClient || first.Status().Code != codes.Error || second.Status().Code == codes.Error {
+		t.Fatalf("expected a failed then a successful client attempt")
+	}
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+	if a := attrs(first); a["http.response.status_code"].AsInt64() != 503 {
+		t.Fatalf("expected status 503 on the first attempt, got %v", a)
+	}
+	if a := attrs(second); a["http.request.resend_count"].AsInt64() != 1 || a["http.response.status_code"].AsInt64() != 200 {
+		t.Fatalf("expected a resend with status 200, got %v", a)
+	}
+	want := "00-" + second.SpanContext().TraceID().String() + "-" + second.SpanContext().SpanID().String() + "-01"
+	if traceparent.Load() != want {
+		t.Fatalf("expected traceparent %s sent to Kraken, got %v", want, traceparent.Load())
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	if got, want := RetryBudget(2*time.Second, 2), 6*time.Second+600*time.Millisecond; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if got := RetryBudget(time.Second, 0); got != time.Second {
+		t.Fatalf("expected 1s without retries, got %s", got)
+	}
+}
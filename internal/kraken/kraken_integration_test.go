@@ -58,3 +58,82 @@ func keysOf(m map[string]float64) []string {
 	}
 	return ks
 }
+
+func TestKrakenClient_GetOHLC_RealAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	c := NewClient("https://api.kraken.com", &http.Client{Timeout: 6 * time.Second}, 2)
+	res, err := c.GetOHLC(ctx, "XXBTZUSD", 60, 0)
+	if err != nil {
+		t.Fatalf("kraken request failed: %v", err)
+	}
+	if len(res.Candles) == 0 || res.Last == 0 {
+		t.Fatalf("expected candles and last id, got %d candles last=%d", len(res.Candles), res.Last)
+	}
+	for _, cd := range res.Candles {
+		if cd.Close <= 0 || cd.High < cd.Low {
+			t.Fatalf("implausible candle: %+v", cd)
+		}
+	}
+}
+
+func TestKrakenClient_GetTrades_RealAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	c := NewClient("https://api.kraken.com", &http.Client{Timeout: 6 * time.Second}, 2)
+	res, err := c.GetTrades(ctx, "XXBTZUSD", 0)
+	if err != nil {
+		t.Fatalf("kraken request failed: %v", err)
+	}
+	if len(res.Trades) == 0 || res.Last == 0 {
+		t.Fatalf("expected trades and last id, got %d trades last=%d", len(res.Trades), res.Last)
+	}
+	last := res.Trades[len(res.Trades)-1]
+	if last.Price <= 0 || time.Since(last.Time) > 24*time.Hour {
+		t.Fatalf("implausible last trade: %+v", last)
+	}
+}
+
+func TestKrakenClient_GetDepthAndSpread_RealAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	c := NewClient("https://api.kraken.com", &http.Client{Timeout: 6 * time.Second}, 2)
+	ob, err := c.GetDepth(ctx, "XXBTZEUR", 5)
+	if err != nil {
+		t.Fatalf("depth request failed: %v", err)
+	}
+	if len(ob.Bids) == 0 || len(ob.Asks) == 0 || ob.Bids[0].Price >= ob.Asks[0].Price {
+		t.Fatalf("implausible order book: %+v", ob)
+	}
+	sp, err := c.GetSpread(ctx, "XXBTZEUR", 0)
+	if err != nil {
+		t.Fatalf("spread request failed: %v", err)
+	}
+	if len(sp.Points) == 0 {
+		t.Fatalf("expected spread points")
+	}
+}
+
+func TestKrakenClient_SystemStatusAndTime_RealAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	c := NewClient("https://api.kraken.com", &http.Client{Timeout: 6 * time.Second}, 2)
+	st, err := c.GetSystemStatus(ctx)
+	if err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+	if st.Status == "" {
+		t.Fatalf("empty system status")
+	}
+	ts, err := c.GetServerTime(ctx)
+	if err != nil {
+		t.Fatalf("time request failed: %v", err)
+	}
+	if d := time.Since(ts); d > time.Minute || d < -time.Minute {
+		t.Fatalf("server time %v too far from local clock", ts)
+	}
+}
@@ -0,0 +1,110 @@
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Intervals lists the OHLC candle intervals (in minutes) supported by Kraken.
+var Intervals = []int{1, 5, 15, 30, 60, 240, 1440, 10080, 21600}
+
+// ValidInterval reports whether interval (minutes) is supported by Kraken OHLC.
+func ValidInterval(interval int) bool {
+	for _, i := range Intervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// Candle is a single OHLC data point. Time is the candle open time (unix seconds).
+type Candle struct {
+	Time   int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	VWAP   float64
+	Volume float64
+	Count  int
+}
+
+// OHLC is the result of GetOHLC. Candles are ordered by time ascending; the
+// last one is usually the current, not yet closed candle.
+// Last is the id to pass as since to poll for committed data only.
+type OHLC struct {
+	Candles []Candle
+	Last    int64
+}
+
+// GetOHLC returns OHLC candles for a Kraken pair code and interval (minutes).
+// since (unix seconds) is optional; 0 returns the most recent candles Kraken keeps.
+func (c *Client) GetOHLC(ctx context.Context, krakenPair string, interval int, since int64) (*OHLC, error) {
+	if !ValidInterval(interval) {
+		return nil, fmt.Errorf("unsupported interval: %d", interval)
+	}
+	q := url.Values{}
+	q.Set("pair", krakenPair)
+	q.Set("interval", strconv.Itoa(interval))
+	if since > 0 {
+		q.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	var result map[string]json.RawMessage
+	if err := c.getPublic(ctx, "OHLC", q, &result); err != nil {
+		return nil, err
+	}
+	out := &OHLC{}
+	for key, raw := range result {
+		if key == "last" {
+			if err := json.Unmarshal(raw, &out.Last); err != nil {
+				return nil, fmt.Errorf("parse last: %w", err)
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, &out.Candles); err != nil {
+			return nil, fmt.Errorf("parse ohlc for %s: %w", key, err)
+		}
+	}
+	return out, nil
+}
+
+// UnmarshalJSON decodes Kraken's mixed-type OHLC row:
+// [time, "open", "high", "low", "close", "vwap", "volume", count].
+func (c *Candle) UnmarshalJSON(b []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(b, &row); err != nil {
+		return err
+	}
+	if len(row) < 8 {
+		return fmt.Errorf("ohlc row has %d fields, want 8", len(row))
+	}
+	if err := json.Unmarshal(row[0], &c.Time); err != nil {
+		return fmt.Errorf("ohlc time: %w", err)
+	}
+	for i, dst := range []*float64{&c.Open, &c.High, &c.Low, &c.Close, &c.VWAP, &c.Volume} {
+		f, err := parseStringFloat(row[i+1])
+		if err != nil {
+			return fmt.Errorf("ohlc field %d: %w", i+1, err)
+		}
+		*dst = f
+	}
+	if err := json.Unmarshal(row[7], &c.Count); err != nil {
+		return fmt.Errorf("ohlc count: %w", err)
+	}
+	return nil
+}
+
+// parseStringFloat parses a JSON string holding a decimal number, as Kraken
+// encodes prices and volumes.
+func parseStringFloat(raw json.RawMessage) (float64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,60 @@
+package kraken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_GetOHLC_DecodesRows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/OHLC" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("interval"); got != "5" {
+			t.Errorf("expected interval 5, got %q", got)
+		}
+		if got := r.URL.Query().Get("since"); got != "1700000000" {
+			t.Errorf("expected since 1700000000, got %q", got)
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":[
+			[1700000100,"52000.1","52100.0","51900.5","52050.2","52010.3","1.25",42],
+			[1700000400,"52050.2","52060.0","52040.0","52055.0","52051.0","0.5",3]
+		],"last":1700000100}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	res, err := c.GetOHLC(context.Background(), "XXBTZUSD", 5, 1700000000)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Last != 1700000100 {
+		t.Fatalf("expected last 1700000100, got %d", res.Last)
+	}
+	if len(res.Candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(res.Candles))
+	}
+	want := Candle{Time: 1700000100, Open: 52000.1, High: 52100.0, Low: 51900.5, Close: 52050.2, VWAP: 52010.3, Volume: 1.25, Count: 42}
+	if res.Candles[0] != want {
+		t.Fatalf("unexpected candle: %+v", res.Candles[0])
+	}
+}
+
+func TestClient_GetOHLC_InvalidInterval(t *testing.T) {
+	c := NewClient("http://127.0.0.1:0", nil, 0)
+	if _, err := c.GetOHLC(context.Background(), "XXBTZUSD", 7, 0); err == nil {
+		t.Fatalf("expected error for unsupported interval")
+	}
+}
+
+func TestCandle_UnmarshalJSON_Malformed(t *testing.T) {
+	var c Candle
+	if err := c.UnmarshalJSON([]byte(`[1700000100,"x","1","1","1","1","1",1]`)); err == nil {
+		t.Fatalf("expected error for non-numeric price")
+	}
+	if err := c.UnmarshalJSON([]byte(`[1700000100,"1"]`)); err == nil {
+		t.Fatalf("expected error for short row")
+	}
+}
@@ -0,0 +1,40 @@
+package kraken
+
+import (
+	"context"
+	"time"
+)
+
+// Kraken system status values.
+const (
+	StatusOnline      = "online"
+	StatusMaintenance = "maintenance"
+	StatusCancelOnly  = "cancel_only"
+	StatusPostOnly    = "post_only"
+)
+
+// SystemStatus is the result of GetSystemStatus.
+type SystemStatus struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetSystemStatus returns Kraken's current system status.
+func (c *Client) GetSystemStatus(ctx context.Context) (*SystemStatus, error) {
+	var out SystemStatus
+	if err := c.getPublic(ctx, "SystemStatus", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetServerTime returns Kraken's server time (second resolution).
+func (c *Client) GetServerTime(ctx context.Context) (time.Time, error) {
+	var out struct {
+		UnixTime int64 `json:"unixtime"`
+	}
+	if err := c.getPublic(ctx, "Time", nil, &out); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(out.UnixTime, 0).UTC(), nil
+}
@@ -0,0 +1,38 @@
+package kraken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_SystemStatusAndTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/SystemStatus":
+			w.Write([]byte(`{"error":[],"result":{"status":"maintenance","timestamp":"2024-01-01T12:00:00Z"}}`))
+		case "/0/public/Time":
+			w.Write([]byte(`{"error":[],"result":{"unixtime":1700000000,"rfc1123":"Tue, 14 Nov 23 22:13:20 +0000"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	st, err := c.GetSystemStatus(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if st.Status != StatusMaintenance || st.Timestamp.IsZero() {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	ts, err := c.GetServerTime(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if ts.Unix() != 1700000000 {
+		t.Fatalf("unexpected server time: %v", ts)
+	}
+}
@@ -0,0 +1,108 @@
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Trade is a single public trade.
+type Trade struct {
+	Price     float64
+	Volume    float64
+	Time      time.Time
+	Side      string // "buy" or "sell"
+	OrderType string // "market" or "limit"
+	Misc      string
+	ID        int64
+}
+
+// Trades is the result of GetTrades. Trades are ordered by time ascending.
+// Last is the id (nanosecond timestamp) to pass as since to poll for newer trades.
+type Trades struct {
+	Trades []Trade
+	Last   int64
+}
+
+// GetTrades returns recent public trades for a Kraken pair code.
+// since is optional; 0 returns the most recent trades Kraken serves (up to 1000).
+func (c *Client) GetTrades(ctx context.Context, krakenPair string, since int64) (*Trades, error) {
+	q := url.Values{}
+	q.Set("pair", krakenPair)
+	if since > 0 {
+		q.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	var result map[string]json.RawMessage
+	if err := c.getPublic(ctx, "Trades", q, &result); err != nil {
+		return nil, err
+	}
+	out := &Trades{}
+	for key, raw := range result {
+		if key == "last" {
+			var last string
+			if err := json.Unmarshal(raw, &last); err != nil {
+				return nil, fmt.Errorf("parse last: %w", err)
+			}
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse last %s: %w", last, err)
+			}
+			out.Last = n
+			continue
+		}
+		if err := json.Unmarshal(raw, &out.Trades); err != nil {
+			return nil, fmt.Errorf("parse trades for %s: %w", key, err)
+		}
+	}
+	return out, nil
+}
+
+// UnmarshalJSON decodes Kraken's mixed-type trade row:
+// ["price", "volume", time, "b|s", "m|l", "misc", trade_id].
+func (t *Trade) UnmarshalJSON(b []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(b, &row); err != nil {
+		return err
+	}
+	if len(row) < 6 {
+		return fmt.Errorf("trade row has %d fields, want at least 6", len(row))
+	}
+	var err error
+	if t.Price, err = parseStringFloat(row[0]); err != nil {
+		return fmt.Errorf("trade price: %w", err)
+	}
+	if t.Volume, err = parseStringFloat(row[1]); err != nil {
+		return fmt.Errorf("trade volume: %w", err)
+	}
+	var ts float64
+	if err := json.Unmarshal(row[2], &ts); err != nil {
+		return fmt.Errorf("trade time: %w", err)
+	}
+	sec, frac := math.Modf(ts)
+	t.Time = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+
+	var side, typ string
+	if err := json.Unmarshal(row[3], &side); err != nil {
+		return fmt.Errorf("trade side: %w", err)
+	}
+	if err := json.Unmarshal(row[4], &typ); err != nil {
+		return fmt.Errorf("trade type: %w", err)
+	}
+	t.Side = map[string]string{"b": "buy", "s": "sell"}[side]
+	t.OrderType = map[string]string{"m": "market", "l": "limit"}[typ]
+	if err := json.Unmarshal(row[5], &t.Misc); err != nil {
+		return fmt.Errorf("trade misc: %w", err)
+	}
+	// trade_id was added later; older responses omit it
+	if len(row) > 6 {
+		if err := json.Unmarshal(row[6], &t.ID); err != nil {
+			return fmt.Errorf("trade id: %w", err)
+		}
+	}
+	return nil
+}
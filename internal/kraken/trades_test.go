@@ -0,0 +1,41 @@
+package kraken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_GetTrades_DecodesRows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/Trades" || r.URL.Query().Get("pair") != "XXBTZEUR" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":[
+			["50000.10000","0.01000000",1700000000.5,"b","l","",101],
+			["50001.00000","0.20000000",1700000001.25,"s","m","",102]
+		],"last":"1700000001250000000"}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, srv.Client(), 0)
+	res, err := c.GetTrades(context.Background(), "XXBTZEUR", 0)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Last != 1700000001250000000 {
+		t.Fatalf("unexpected last: %d", res.Last)
+	}
+	if len(res.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(res.Trades))
+	}
+	tr := res.Trades[1]
+	if tr.Price != 50001 || tr.Volume != 0.2 || tr.Side != "sell" || tr.OrderType != "market" || tr.ID != 102 {
+		t.Fatalf("unexpected trade: %+v", tr)
+	}
+	if want := time.Unix(1700000001, 250000000); !tr.Time.Equal(want) {
+		t.Fatalf("expected time %v, got %v", want, tr.Time)
+	}
+}
@@ -0,0 +1,28 @@
+// Package logging builds the process logger from the configured format and
+// level.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"bitcoin-prices/internal/requestid"
+)
+
+// New returns a logger writing "text" (logfmt) or "json" records to w at
+// the level held by level, which may be a *slog.LevelVar to change it at
+// runtime. Records logged with a request's context carry its request ID.
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	return slog.New(requestid.NewHandler(h)), nil
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"bitcoin-prices/internal/requestid"
+)
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	logger, err := New(&buf, "json", level)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.InfoContext(requestid.NewContext(context.Background(), "req-1"), "http", "status", 200)
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "http" || rec["status"] != float64(200) || rec["request_id"] != "req-1" {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+
+	buf.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+	if buf.Len() == 0 {
+		t.Fatalf("expected the level to follow the LevelVar")
+	}
+}
+
+func TestNew_Formats(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	logger.Info("hello")
+	if !bytes.Contains(buf.Bytes(), []byte("msg=hello")) {
+		t.Fatalf("expected a text record, got %q", buf.String())
+	}
+	if _, err := New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Fatalf("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds named counters and serves them in the Prometheus text format.
+// Zero-value is not ready; use NewRegistry.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Counter)}
+}
+
+// Counter returns the counter registered under name, creating it if needed.
+// Label names are fixed at creation.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]uint64)}
+	r.counters[name] = c
+	return c
+}
+
+// Handler serves all counters in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// Write writes all counters, sorted by name, in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.counters))
+	for n := range r.counters {
+		names = append(names, n)
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+	for _, n := range names {
+		r.mu.Lock()
+		c := r.counters[n]
+		r.mu.Unlock()
+		c.writeTo(w)
+	}
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64 // keyed by joined label values
+}
+
+// Inc increments the counter for the given label values (one per label name).
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter by n for the given label values.
+func (c *Counter) Add(n uint64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += n
+	c.mu.Unlock()
+}
+
+// Value returns the current count for the given label values.
+func (c *Counter) Value(labelValues ...string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, c.formatLabels(k), c.values[k])
+	}
+}
+
+func (c *Counter) formatLabels(key string) string {
+	if len(c.labels) == 0 {
+		return ""
+	}
+	vals := strings.Split(key, "\xff")
+	parts := make([]string, 0, len(c.labels))
+	for i, l := range c.labels {
+		v := ""
+		if i < len(vals) {
+			v = vals[i]
+		}
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, l, v))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounter_IncAndExpose(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("price_rejections_total", "Rejected prices.", "pair", "reason")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Inc("BTC/USD", "deviation")
+		}()
+	}
+	wg.Wait()
+	c.Inc("BTC/EUR", `non"positive`)
+	if got := c.Value("BTC/USD", "deviation"); got != 10 {
+		t.Fatalf("expected 10, got %d", got)
+	}
+	if r.Counter("price_rejections_total", "ignored") != c {
+		t.Fatalf("expected the same counter for the same name")
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE price_rejections_total counter",
+		`price_rejections_total{pair="BTC/USD",reason="deviation"} 10`,
+		`price_rejections_total{pair="BTC/EUR",reason="non\"positive"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+}
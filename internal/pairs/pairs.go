@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 // Supported external pairs and Kraken canonical pair symbols.
@@ -17,26 +18,64 @@ var (
 		"BTC/EUR": "XXBTZEUR",
 		"BTC/CHF": "XXBTZCHF",
 	}
+
+	// enabled restricts the accepted pairs; nil means all Supported pairs.
+	enabled atomic.Pointer[map[string]bool]
 )
 
+// SetEnabled restricts the pairs NormalizePair, NormalizePairs and Direct
+// accept to ps. An empty list enables every supported pair. It is safe to call
+// while requests are being served.
+func SetEnabled(ps []string) error {
+	if len(ps) == 0 {
+		enabled.Store(nil)
+		return nil
+	}
+	set := make(map[string]bool, len(ps))
+	for _, raw := range ps {
+		p, err := Canonical(raw)
+		if err != nil {
+			return err
+		}
+		set[p] = true
+	}
+	enabled.Store(&set)
+	return nil
+}
+
+// Enabled returns the enabled pairs in the order of Supported.
+func Enabled() []string {
+	set := enabled.Load()
+	out := make([]string, 0, len(Supported))
+	for _, p := range Supported {
+		if set == nil || (*set)[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func isEnabled(p string) bool {
+	set := enabled.Load()
+	return set == nil || (*set)[p]
+}
+
 // NormalizePairs parses a comma-separated list from query and validates.
 // Returns sorted unique external pair strings.
 func NormalizePairs(raw string) ([]string, error) {
 	if strings.TrimSpace(raw) == "" {
-		// default: all
-		out := make([]string, len(Supported))
-		copy(out, Supported)
-		return out, nil
+		// default: all enabled
+		return Enabled(), nil
 	}
 	split := strings.Split(raw, ",")
 	set := make(map[string]struct{}, len(split))
 	for _, item := range split {
-		p := strings.ToUpper(strings.TrimSpace(item))
-		if p == "" {
+		if strings.TrimSpace(item) == "" {
 			continue
 		}
-		if _, ok := toKraken[p]; !ok {
-			return nil, fmt.Errorf("unsupported pair: %s", p)
+		p, err := NormalizePair(item)
+		if err != nil {
+			return nil, err
 		}
 		set[p] = struct{}{}
 	}
@@ -51,6 +90,63 @@ func NormalizePairs(raw string) ([]string, error) {
 	return out, nil
 }
 
+// NormalizePair validates a single external pair, e.g. " btc/usd" -> "BTC/USD".
+// Pairs disabled with SetEnabled are rejected.
+func NormalizePair(raw string) (string, error) {
+	p, err := Canonical(raw)
+	if err != nil {
+		return "", err
+	}
+	if !isEnabled(p) {
+		return "", fmt.Errorf("pair disabled: %s", p)
+	}
+	return p, nil
+}
+
+// Canonical validates a single external pair against Supported, ignoring
+// SetEnabled.
+func Canonical(raw string) (string, error) {
+	p := strings.ToUpper(strings.TrimSpace(raw))
+	if p == "" {
+		return "", fmt.Errorf("no pair provided")
+	}
+	if _, ok := toKraken[p]; !ok {
+		return "", fmt.Errorf("unsupported pair: %s", p)
+	}
+	return p, nil
+}
+
+// KrakenSymbol returns the Kraken pair code for a single external pair.
+func KrakenSymbol(extPair string) (string, bool) {
+	sym, ok := toKraken[extPair]
+	return sym, ok
+}
+
+// Direct finds the supported pair trading asset from against asset to.
+// inverse is true when the pair is quoted the other way round (to/from).
+// Only direct, enabled pairs are considered; no cross rates are derived.
+func Direct(from, to string) (extPair string, inverse bool, ok bool) {
+	from = strings.ToUpper(strings.TrimSpace(from))
+	to = strings.ToUpper(strings.TrimSpace(to))
+	if _, ok := toKraken[from+"/"+to]; ok && isEnabled(from+"/"+to) {
+		return from + "/" + to, false, true
+	}
+	if _, ok := toKraken[to+"/"+from]; ok && isEnabled(to+"/"+from) {
+		return to + "/" + from, true, true
+	}
+	return "", false, false
+}
+
+// ExternalPair returns the external pair for a Kraken pair code.
+func ExternalPair(krakenSym string) (string, bool) {
+	for p, sym := range toKraken {
+		if sym == krakenSym {
+			return p, true
+		}
+	}
+	return "", false
+}
+
 // KrakenSymbols returns Kraken pair codes for the provided external pairs.
 func KrakenSymbols(extPairs []string) []string {
 	out := make([]string, 0, len(extPairs))
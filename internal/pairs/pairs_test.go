@@ -27,3 +27,63 @@ func TestNormalizePairs_Invalid(t *testing.T) {
 		t.Fatalf("expected error for invalid pair")
 	}
 }
+
+func TestNormalizePair(t *testing.T) {
+	p, err := NormalizePair(" btc/chf ")
+	if err != nil || p != "BTC/CHF" {
+		t.Fatalf("expected BTC/CHF, got %q err=%v", p, err)
+	}
+	if _, err := NormalizePair(""); err == nil {
+		t.Fatalf("expected error for empty pair")
+	}
+	if _, err := NormalizePair("ETH/USD"); err == nil {
+		t.Fatalf("expected error for invalid pair")
+	}
+}
+
+func TestDirect(t *testing.T) {
+	if p, inv, ok := Direct("btc", "CHF"); !ok || inv || p != "BTC/CHF" {
+		t.Fatalf("expected BTC/CHF direct, got %q inverse=%v ok=%v", p, inv, ok)
+	}
+	if p, inv, ok := Direct("EUR", "BTC"); !ok || !inv || p != "BTC/EUR" {
+		t.Fatalf("expected BTC/EUR inverse, got %q inverse=%v ok=%v", p, inv, ok)
+	}
+	if _, _, ok := Direct("USD", "EUR"); ok {
+		t.Fatalf("expected no direct pair for USD/EUR")
+	}
+}
+
+func TestExternalPair(t *testing.T) {
+	if p, ok := ExternalPair("XXBTZEUR"); !ok || p != "BTC/EUR" {
+		t.Fatalf("expected BTC/EUR, got %q ok=%v", p, ok)
+	}
+	if _, ok := ExternalPair("XETHZUSD"); ok {
+		t.Fatalf("expected unknown symbol")
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	t.Cleanup(func() { SetEnabled(nil) })
+	if err := SetEnabled([]string{"btc/usd", "BTC/EUR"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if ps, _ := NormalizePairs(""); len(ps) != 2 || ps[0] != "BTC/USD" || ps[1] != "BTC/EUR" {
+		t.Fatalf("expected enabled pairs by default, got %v", ps)
+	}
+	if _, err := NormalizePair("BTC/CHF"); err == nil {
+		t.Fatalf("expected error for disabled pair")
+	}
+	if p, err := Canonical("BTC/CHF"); err != nil || p != "BTC/CHF" {
+		t.Fatalf("expected disabled pair to stay canonical, got %q err=%v", p, err)
+	}
+	if _, _, ok := Direct("CHF", "BTC"); ok {
+		t.Fatalf("expected no direct pair for a disabled pair")
+	}
+	if err := SetEnabled([]string{"ETH/USD"}); err == nil {
+		t.Fatalf("expected error for unsupported pair")
+	}
+	SetEnabled(nil)
+	if len(Enabled()) != len(Supported) {
+		t.Fatalf("expected all pairs after reset, got %v", Enabled())
+	}
+}
@@ -0,0 +1,78 @@
+// Package requestid carries a request ID through contexts so that the log
+// records of one request, and the upstream calls it makes, can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Header is the HTTP header the ID is received, returned and forwarded in.
+const Header = "X-Request-ID"
+
+// maxLen bounds IDs accepted from clients.
+const maxLen = 128
+
+type ctxKey struct{}
+
+// New returns a random 128-bit ID in hex.
+func New() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Valid reports whether id, typically sent by a client or an upstream proxy,
+// is safe to log and echo: 1 to 128 letters, digits or -_.:+/= (UUIDs,
+// base64, trace IDs).
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the ID in ctx, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Handler adds a request_id attribute to records logged with a context
+// carrying an ID (logger.InfoContext and friends).
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h.
+func NewHandler(h slog.Handler) *Handler { return &Handler{Handler: h} }
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
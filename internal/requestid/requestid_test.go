@@ -0,0 +1,38 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	for _, id := range []string{"abc", "0f8fad5b-d9cb-469f-a165-70867728950e", "dGVzdA==", New()} {
+		if !Valid(id) {
+			t.Errorf("expected %q to be valid", id)
+		}
+	}
+	for _, id := range []string{"", "has space", "new\nline", `quote"`, strings.Repeat("a", 129)} {
+		if Valid(id) {
+			t.Errorf("expected %q to be invalid", id)
+		}
+	}
+	if New() == New() {
+		t.Fatal("expected distinct IDs")
+	}
+}
+
+func TestHandler_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+	ctx := NewContext(context.Background(), "req-1")
+
+	logger.InfoContext(ctx, "with id")
+	logger.Info("without id")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "component=test request_id=req-1") || strings.Contains(lines[1], "request_id") {
+		t.Fatalf("unexpected log output:\n%s", buf.String())
+	}
+}
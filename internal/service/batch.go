@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bitcoin-prices/internal/decimal"
+	"bitcoin-prices/internal/pairs"
+)
+
+const (
+	// MaxBatchPairs bounds the pairs accepted in a single batch request.
+	MaxBatchPairs = 500
+	// defaultChunkSize is the number of pairs sent to Kraken per Ticker call.
+	defaultChunkSize = 20
+	// maxConcurrentChunks bounds parallel Kraken calls per batch.
+	maxConcurrentChunks = 4
+)
+
+// Fields that may be requested via "include" in a batch request.
+var batchFields = map[string]bool{"fetched_at": true, "last_trade_at": true, "stale": true, "age_ms": true}
+
+// WithChunkSize sets how many pairs a batch sends to Kraken per Ticker call
+// (default 20).
+func WithChunkSize(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.chunkSize = n
+		}
+	}
+}
+
+// BatchItem is a single pair in a batch request with its options.
+type BatchItem struct {
+	Pair      string        // as given by the caller
+	MaxAge    time.Duration // a cached price older than this is refetched; 0 uses the cache TTL
+	Precision int           // decimal places of the amount; -1 leaves it unrounded
+	Include   []string
+}
+
+// BatchResult is the per-pair outcome of GetLTPBatch.
+type BatchResult struct {
+	Item  BatchItem
+	Pair  string // normalized pair, empty if invalid
+	Quote Quote
+	Err   error
+}
+
+type batchPairJSON struct {
+	Pair      string   `json:"pair"`
+	MaxAge    *float64 `json:"max_age"`
+	Precision *int     `json:"precision"`
+	Include   []string `json:"include"`
+}
+
+// UnmarshalJSON accepts either a plain pair string or an object with options.
+func (b *batchPairJSON) UnmarshalJSON(data []byte) error {
+	var p string
+	if err := json.Unmarshal(data, &p); err == nil {
+		b.Pair = p
+		return nil
+	}
+	type plain batchPairJSON
+	return json.Unmarshal(data, (*plain)(b))
+}
+
+// ParseBatchRequest decodes a batch request:
+//
+//	{"pairs": ["BTC/USD", {"pair": "BTC/EUR", "max_age": 5, "precision": 2}],
+//	 "max_age": 10, "precision": 4, "include": ["fetched_at"]}
+//
+// Top-level options are defaults for pairs that do not set their own.
+// max_age is in seconds. Pair validity is checked per item by GetLTPBatch.
+func ParseBatchRequest(r io.Reader) ([]BatchItem, error) {
+	var body struct {
+		Pairs     []batchPairJSON `json:"pairs"`
+		MaxAge    *float64        `json:"max_age"`
+		Precision *int            `json:"precision"`
+		Include   []string        `json:"include"`
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if len(body.Pairs) == 0 {
+		return nil, errors.New("no pairs provided")
+	}
+	if len(body.Pairs) > MaxBatchPairs {
+		return nil, fmt.Errorf("too many pairs: %d (max %d)", len(body.Pairs), MaxBatchPairs)
+	}
+	def := BatchItem{Precision: -1}
+	if err := applyBatchOptions(&def, body.MaxAge, body.Precision, body.Include); err != nil {
+		return nil, err
+	}
+	items := make([]BatchItem, 0, len(body.Pairs))
+	for i, p := range body.Pairs {
+		it := def
+		it.Pair = p.Pair
+		if err := applyBatchOptions(&it, p.MaxAge, p.Precision, p.Include); err != nil {
+			return nil, fmt.Errorf("pairs[%d]: %w", i, err)
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func applyBatchOptions(it *BatchItem, maxAge *float64, precision *int, include []string) error {
+	if maxAge != nil {
+		if *maxAge < 0 {
+			return errors.New("max_age must not be negative")
+		}
+		it.MaxAge = time.Duration(*maxAge * float64(time.Second))
+	}
+	if precision != nil {
+		if *precision < 0 || *precision > maxPrecision {
+			return fmt.Errorf("invalid precision: %d", *precision)
+		}
+		it.Precision = *precision
+	}
+	if include != nil {
+		for _, f := range include {
+			if !batchFields[f] {
+				return fmt.Errorf("unsupported include field: %s", f)
+			}
+		}
+		it.Include = include
+	}
+	return nil
+}
+
+// GetLTPBatch returns a result per item in request order. Invalid pairs and
+// failed fetches are reported per item instead of failing the whole batch.
+// Pairs that are not cached (or older than their max_age) are split into
+// chunks and fetched from Kraken concurrently.
+func (s *Service) GetLTPBatch(ctx context.Context, items []BatchItem) []BatchResult {
+	results := make([]BatchResult, len(items))
+	var missing []string
+	seen := make(map[string]bool)
+	for i, it := range items {
+		results[i].Item = it
+		p, err := pairs.NormalizePair(it.Pair)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Pair = p
+		sym, _ := pairs.KrakenSymbol(p)
+		if q, ok := s.cache.Get(sym); ok && (it.MaxAge == 0 || s.clock.Now().Sub(q.FetchedAt) <= it.MaxAge) {
+			results[i].Quote = q
+			continue
+		}
+		if !seen[sym] {
+			seen[sym] = true
+			missing = append(missing, sym)
+		}
+	}
+
+	fetched, errs := s.fetchChunks(ctx, missing)
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil || !r.Quote.FetchedAt.IsZero() {
+			continue
+		}
+		sym, _ := pairs.KrakenSymbol(r.Pair)
+		if q, ok := fetched[sym]; ok {
+			r.Quote = q
+		} else if err := errs[sym]; err != nil {
+			r.Err = err
+		} else {
+			r.Err = fmt.Errorf("no price returned for %s", r.Pair)
+		}
+	}
+	return results
+}
+
+// fetchChunks fetches syms from Kraken in chunks of s.chunkSize, running up to
+// maxConcurrentChunks calls at once. Errors are reported per symbol. Unlike
+// a valuation, batch items are independent: a price the guard rejects falls
+// back to the last accepted quote, as for GetLTP, and its fetched_at shows so.
+func (s *Service) fetchChunks(ctx context.Context, syms []string) (map[string]Quote, map[string]error) {
+	quotes := make(map[string]Quote, len(syms))
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentChunks)
+	for start := 0; start < len(syms); start += s.chunkSize {
+		chunk := syms[start:min(start+s.chunkSize, len(syms))]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fresh, err := s.kraken.GetLastTradeClosed(ctx, chunk)
+			now := s.clock.Now()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				for _, sym := range chunk {
+					errs[sym] = fmt.Errorf("kraken: %w", err)
+				}
+				return
+			}
+			s.lastFetch.Store(now.UnixNano())
+			for k, v := range fresh {
+				if q, ok := s.acceptQuote(k, Quote{Price: v, FetchedAt: now}); ok {
+					quotes[k] = q
+				}
+			}
+		}(chunk)
+	}
+	wg.Wait()
+	return quotes, errs
+}
+
+// BuildBatchResponse formats batch results for the API response, with ages
+// and staleness as of the service clock. lastTrades is only consulted for
+// items that include last_trade_at or stale.
+func (s *Service) BuildBatchResponse(results []BatchResult, lastTrades map[string]time.Time) map[string]any {
+	now, staleAfter := s.clock.Now(), s.staleAfter
+	out := make([]map[string]any, 0, len(results))
+	for _, r := range results {
+		pair := r.Pair
+		if pair == "" {
+			pair = strings.ToUpper(strings.TrimSpace(r.Item.Pair))
+		}
+		item := map[string]any{"pair": pair}
+		if r.Err != nil {
+			item["error"] = r.Err.Error()
+			out = append(out, item)
+			continue
+		}
+		amount := r.Quote.Price
+		if r.Item.Precision >= 0 {
+			amount, _ = strconv.ParseFloat(decimal.Round(decimal.FromFloat(amount), r.Item.Precision, decimal.HalfEven), 64)
+		}
+		item["amount"] = amount
+		for _, f := range r.Item.Include {
+			switch f {
+			case "fetched_at":
+				item["fetched_at"] = r.Quote.FetchedAt.UTC().Format(time.RFC3339Nano)
+			case "age_ms":
+				item["age_ms"] = now.Sub(r.Quote.FetchedAt).Milliseconds()
+			case "last_trade_at":
+				if t, ok := lastTrades[r.Pair]; ok {
+					item["last_trade_at"] = t.UTC().Format(time.RFC3339Nano)
+				}
+			case "stale":
+				if t, ok := lastTrades[r.Pair]; ok {
+					item["stale"] = staleAfter > 0 && now.Sub(t) > staleAfter
+				}
+			}
+		}
+		out = append(out, item)
+	}
+	return map[string]any{"results": out}
+}
+
+// TradeTimePairs returns the valid pairs in items that request last_trade_at
+// or stale, normalized as by GetLTPBatch, for use with LastTradeTimes.
+func TradeTimePairs(items []BatchItem) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, it := range items {
+		p, err := pairs.NormalizePair(it.Pair)
+		if err != nil || seen[p] {
+			continue
+		}
+		for _, f := range it.Include {
+			if f == "last_trade_at" || f == "stale" {
+				seen[p] = true
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
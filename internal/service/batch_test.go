@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+// chunkKraken is a concurrency-safe ticker mock that records requested chunks.
+type chunkKraken struct {
+	mu     sync.Mutex
+	chunks [][]string
+	resp   map[string]float64
+	fail   map[string]bool // fail any chunk containing these symbols
+}
+
+func (m *chunkKraken) GetLastTradeClosed(ctx context.Context, krakenPairs []string) (map[string]float64, error) {
+	m.mu.Lock()
+	m.chunks = append(m.chunks, krakenPairs)
+	m.mu.Unlock()
+	out := make(map[string]float64)
+	for _, k := range krakenPairs {
+		if m.fail[k] {
+			return nil, errors.New("boom")
+		}
+		if v, ok := m.resp[k]; ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func TestParseBatchRequest(t *testing.T) {
+	items, err := ParseBatchRequest(strings.NewReader(`{
+		"pairs": ["BTC/USD", {"pair": "btc/eur", "max_age": 2.5, "precision": 1, "include": ["age_ms"]}],
+		"max_age": 10, "precision": 4, "include": ["fetched_at"]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if items[0].MaxAge != 10*time.Second || items[0].Precision != 4 || items[0].Include[0] != "fetched_at" {
+		t.Fatalf("expected defaults on first item, got %+v", items[0])
+	}
+	if items[1].MaxAge != 2500*time.Millisecond || items[1].Precision != 1 || items[1].Include[0] != "age_ms" {
+		t.Fatalf("expected overrides on second item, got %+v", items[1])
+	}
+	for _, bad := range []string{`{"pairs":[]}`, `{"pairs":["BTC/USD"],"include":["bogus"]}`, `{"pairs":[{"pair":"BTC/USD","precision":-2}]}`, `{"pairs":["BTC/USD"],"extra":1}`} {
+		if _, err := ParseBatchRequest(strings.NewReader(bad)); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestService_GetLTPBatch_ChunksAndPerPairErrors(t *testing.T) {
+	mk := &chunkKraken{
+		resp: map[string]float64{"XXBTZUSD": 52000.12, "XXBTZEUR": 50000.12, "XXBTZCHF": 49000.12},
+		fail: map[string]bool{"XXBTZCHF": true},
+	}
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	s := New(mk, time.Minute, WithChunkSize(1), WithClock(clk))
+	items := []BatchItem{{Pair: "BTC/USD"}, {Pair: "ETH/USD"}, {Pair: "BTC/EUR"}, {Pair: "BTC/CHF"}, {Pair: "btc/usd"}}
+
+	res := s.GetLTPBatch(context.Background(), items)
+	if len(res) != len(items) {
+		t.Fatalf("expected %d results, got %d", len(items), len(res))
+	}
+	if len(mk.chunks) != 3 {
+		t.Fatalf("expected 3 single-pair chunks, got %v", mk.chunks)
+	}
+	if res[0].Err != nil || res[0].Quote.Price != 52000.12 || res[4].Quote.Price != 52000.12 {
+		t.Fatalf("unexpected BTC/USD results: %+v %+v", res[0], res[4])
+	}
+	if res[1].Err == nil || res[3].Err == nil {
+		t.Fatalf("expected per-pair errors, got %+v %+v", res[1], res[3])
+	}
+	if res[2].Err != nil || res[2].Quote.Price != 50000.12 {
+		t.Fatalf("unexpected BTC/EUR result: %+v", res[2])
+	}
+
+	// max_age: a cached price is reused unless it is older than requested
+	clk.Advance(5 * time.Second)
+	s.GetLTPBatch(context.Background(), []BatchItem{{Pair: "BTC/USD"}})
+	if len(mk.chunks) != 3 {
+		t.Fatalf("expected cache hit, got %v", mk.chunks)
+	}
+	s.GetLTPBatch(context.Background(), []BatchItem{{Pair: "BTC/USD", MaxAge: time.Second}})
+	if len(mk.chunks) != 4 {
+		t.Fatalf("expected refetch for max_age, got %v", mk.chunks)
+	}
+}
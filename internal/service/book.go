@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// KrakenBook is the optional upstream capability needed for order book and spread data.
+type KrakenBook interface {
+	GetDepth(ctx context.Context, krakenPair string, count int) (*kraken.OrderBook, error)
+	GetSpread(ctx context.Context, krakenPair string, since int64) (*kraken.Spreads, error)
+}
+
+// BookLevel is an order book level with the volume accumulated from the top of book.
+type BookLevel struct {
+	Price      float64
+	Volume     float64
+	Cumulative float64
+}
+
+// OrderBook is a depth snapshot with top-of-book metrics.
+type OrderBook struct {
+	Bids      []BookLevel
+	Asks      []BookLevel
+	Mid       float64
+	Spread    float64
+	SpreadBps float64
+}
+
+// Spread is the latest top-of-book observation with derived metrics.
+type Spread struct {
+	Time      time.Time
+	Bid       float64
+	Ask       float64
+	Mid       float64
+	Spread    float64
+	SpreadBps float64
+}
+
+// GetOrderBook returns a depth snapshot for an external pair with up to depth
+// levels per side. Snapshots are cached for the service TTL.
+func (s *Service) GetOrderBook(ctx context.Context, extPair string, depth int) (*OrderBook, error) {
+	sym, ok := pairs.KrakenSymbol(extPair)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pair: %s", extPair)
+	}
+	bc, ok := s.kraken.(KrakenBook)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	ob, err := s.books.GetOrSet(sym+":"+strconv.Itoa(depth), func() (*OrderBook, time.Duration, error) {
+		ob, err := bc.GetDepth(ctx, sym, depth)
+		if err != nil {
+			return nil, unknownPairTTL, krakenErr(err)
+		}
+		return summarizeBook(ob), 0, nil
+	})
+	return ob, unknownPair(err, sym)
+}
+
+// GetSpread returns the latest top-of-book spread for an external pair.
+// It is cached for the service TTL.
+func (s *Service) GetSpread(ctx context.Context, extPair string) (*Spread, error) {
+	sym, ok := pairs.KrakenSymbol(extPair)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pair: %s", extPair)
+	}
+	bc, ok := s.kraken.(KrakenBook)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	sp, err := s.spreads.GetOrSet(sym, func() (*Spread, time.Duration, error) {
+		res, err := bc.GetSpread(ctx, sym, 0)
+		if err != nil {
+			return nil, unknownPairTTL, krakenErr(err)
+		}
+		if len(res.Points) == 0 {
+			return nil, 0, fmt.Errorf("kraken: no spread data for %s", sym)
+		}
+		p := res.Points[len(res.Points)-1]
+		sp := &Spread{Time: p.Time, Bid: p.Bid, Ask: p.Ask}
+		sp.Mid, sp.Spread, sp.SpreadBps = topOfBook(p.Bid, p.Ask)
+		return sp, 0, nil
+	})
+	return sp, unknownPair(err, sym)
+}
+
+func summarizeBook(ob *kraken.OrderBook) *OrderBook {
+	out := &OrderBook{Bids: cumulate(ob.Bids), Asks: cumulate(ob.Asks)}
+	if len(ob.Bids) > 0 && len(ob.Asks) > 0 {
+		out.Mid, out.Spread, out.SpreadBps = topOfBook(ob.Bids[0].Price, ob.Asks[0].Price)
+	}
+	return out
+}
+
+func cumulate(levels []kraken.Level) []BookLevel {
+	out := make([]BookLevel, 0, len(levels))
+	var total float64
+	for _, l := range levels {
+		total += l.Volume
+		out = append(out, BookLevel{Price: l.Price, Volume: l.Volume, Cumulative: total})
+	}
+	return out
+}
+
+// topOfBook returns mid price, absolute spread and spread in basis points of mid.
+func topOfBook(bid, ask float64) (mid, spread, bps float64) {
+	mid = (bid + ask) / 2
+	spread = ask - bid
+	if mid > 0 {
+		bps = spread / mid * 1e4
+	}
+	return mid, spread, bps
+}
+
+// BuildOrderBookResponse formats an order book snapshot for the API response.
+func BuildOrderBookResponse(extPair string, ob *OrderBook) map[string]any {
+	levels := func(ls []BookLevel) []map[string]any {
+		out := make([]map[string]any, 0, len(ls))
+		for _, l := range ls {
+			out = append(out, map[string]any{"price": l.Price, "volume": l.Volume, "cumulative_volume": l.Cumulative})
+		}
+		return out
+	}
+	return map[string]any{
+		"pair":       extPair,
+		"mid":        ob.Mid,
+		"spread":     ob.Spread,
+		"spread_bps": ob.SpreadBps,
+		"bids":       levels(ob.Bids),
+		"asks":       levels(ob.Asks),
+	}
+}
+
+// BuildSpreadResponse formats a spread observation for the API response.
+func BuildSpreadResponse(extPair string, sp *Spread) map[string]any {
+	return map[string]any{
+		"pair":       extPair,
+		"time":       sp.Time.UTC().Format(time.RFC3339),
+		"bid":        sp.Bid,
+		"ask":        sp.Ask,
+		"mid":        sp.Mid,
+		"spread":     sp.Spread,
+		"spread_bps": sp.SpreadBps,
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+)
+
+type mockBook struct {
+	mockKraken
+	depthCalls int
+	book       *kraken.OrderBook
+	spreads    *kraken.Spreads
+}
+
+func (m *mockBook) GetDepth(ctx context.Context, krakenPair string, count int) (*kraken.OrderBook, error) {
+	m.depthCalls++
+	return m.book, m.err
+}
+
+func (m *mockBook) GetSpread(ctx context.Context, krakenPair string, since int64) (*kraken.Spreads, error) {
+	return m.spreads, m.err
+}
+
+func TestService_GetOrderBook_Metrics(t *testing.T) {
+	mk := &mockBook{book: &kraken.OrderBook{
+		Bids: []kraken.Level{{Price: 99, Volume: 1}, {Price: 98, Volume: 2}},
+		Asks: []kraken.Level{{Price: 101, Volume: 0.5}, {Price: 102, Volume: 1.5}},
+	}}
+	s := New(mk, time.Minute)
+	ctx := context.Background()
+
+	ob, err := s.GetOrderBook(ctx, "BTC/USD", 2)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if ob.Mid != 100 || ob.Spread != 2 || math.Abs(ob.SpreadBps-200) > 1e-9 {
+		t.Fatalf("unexpected metrics: mid=%v spread=%v bps=%v", ob.Mid, ob.Spread, ob.SpreadBps)
+	}
+	if ob.Bids[1].Cumulative != 3 || ob.Asks[1].Cumulative != 2 {
+		t.Fatalf("unexpected cumulative volume: %+v", ob)
+	}
+	if _, err := s.GetOrderBook(ctx, "BTC/USD", 2); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.depthCalls != 1 {
+		t.Fatalf("expected cached snapshot, got %d calls", mk.depthCalls)
+	}
+}
+
+func TestService_GetSpread_UsesLatestPoint(t *testing.T) {
+	mk := &mockBook{spreads: &kraken.Spreads{Points: []kraken.SpreadPoint{
+		{Time: time.Unix(1, 0), Bid: 1, Ask: 2},
+		{Time: time.Unix(2, 0), Bid: 199.9, Ask: 200.1},
+	}}}
+	s := New(mk, time.Minute)
+	sp, err := s.GetSpread(context.Background(), "BTC/CHF")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if sp.Mid != 200 || math.Abs(sp.SpreadBps-10) > 1e-6 || sp.Time.Unix() != 2 {
+		t.Fatalf("unexpected spread: %+v", sp)
+	}
+}
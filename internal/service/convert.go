@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitcoin-prices/internal/decimal"
+	"bitcoin-prices/internal/pairs"
+)
+
+// maxPrecision bounds the decimal places a caller may request.
+const maxPrecision = 18
+
+// ConvertRequest is a validated conversion query.
+type ConvertRequest struct {
+	From      string
+	To        string
+	Amount    *big.Rat
+	Pair      string // direct pair used, e.g. BTC/CHF
+	Inverse   bool   // true when converting quote -> base (divide by the rate)
+	Precision int    // decimal places of the result; -1 selects the default for To
+	Rounding  decimal.RoundingMode
+}
+
+// Conversion is the result of Convert. Amount and Result are exact decimal strings.
+type Conversion struct {
+	ConvertRequest
+	Result      string
+	Rate        float64
+	FetchedAt   time.Time
+	LastTradeAt time.Time // zero if unknown
+}
+
+// WithRounding sets the default rounding mode for conversions (default half_even).
+func WithRounding(mode decimal.RoundingMode) Option {
+	return func(s *Service) { s.rounding = mode }
+}
+
+// ParseConvertQuery validates from, to, amount and the optional precision and
+// rounding query values. Only assets with a direct supported pair are accepted;
+// cross rates are never derived.
+func ParseConvertQuery(q url.Values) (ConvertRequest, error) {
+	from := strings.ToUpper(strings.TrimSpace(q.Get("from")))
+	to := strings.ToUpper(strings.TrimSpace(q.Get("to")))
+	if from == "" || to == "" {
+		return ConvertRequest{}, fmt.Errorf("from and to are required")
+	}
+	pair, inverse, ok := pairs.Direct(from, to)
+	if !ok {
+		return ConvertRequest{}, fmt.Errorf("no direct pair for %s/%s", from, to)
+	}
+	amount, err := decimal.Parse(q.Get("amount"))
+	if err != nil {
+		return ConvertRequest{}, err
+	}
+	if amount.Sign() <= 0 {
+		return ConvertRequest{}, fmt.Errorf("amount must be positive")
+	}
+	req := ConvertRequest{From: from, To: to, Amount: amount, Pair: pair, Inverse: inverse, Precision: -1}
+	if v := q.Get("precision"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || p < 0 || p > maxPrecision {
+			return ConvertRequest{}, fmt.Errorf("invalid precision: %s", v)
+		}
+		req.Precision = p
+	}
+	if v := q.Get("rounding"); v != "" {
+		if req.Rounding, err = decimal.ParseRoundingMode(v); err != nil {
+			return ConvertRequest{}, err
+		}
+	}
+	return req, nil
+}
+
+// Convert converts an amount between two assets using the latest price of
+// their direct pair, with exact decimal arithmetic.
+func (s *Service) Convert(ctx context.Context, req ConvertRequest) (*Conversion, error) {
+	// looked up alongside the price rather than after it
+	lastTrades := make(chan map[string]time.Time, 1)
+	go func() { lastTrades <- s.LastTradeTimes(ctx, []string{req.Pair}) }()
+	quotes, err := s.GetQuotes(ctx, []string{req.Pair})
+	if err != nil {
+		return nil, err
+	}
+	q, ok := quotes[req.Pair]
+	if !ok {
+		return nil, fmt.Errorf("kraken: no price for %s", req.Pair)
+	}
+	if q.Price <= 0 {
+		return nil, fmt.Errorf("kraken: invalid price %v for %s", q.Price, req.Pair)
+	}
+	if req.Precision < 0 {
+		req.Precision = DefaultPrecision(req.To)
+	}
+	if req.Rounding == "" {
+		req.Rounding = s.rounding
+	}
+
+	rate := decimal.FromFloat(q.Price)
+	res := new(big.Rat)
+	if req.Inverse {
+		res.Quo(req.Amount, rate)
+	} else {
+		res.Mul(req.Amount, rate)
+	}
+	out := &Conversion{
+		ConvertRequest: req,
+		Result:         decimal.Round(res, req.Precision, req.Rounding),
+		Rate:           q.Price,
+		FetchedAt:      q.FetchedAt,
+	}
+	if t, ok := (<-lastTrades)[req.Pair]; ok {
+		out.LastTradeAt = t
+	}
+	return out, nil
+}
+
+// DefaultPrecision returns the default decimal places for amounts of asset:
+// 8 (satoshi) for BTC, 2 for fiat currencies.
+func DefaultPrecision(asset string) int {
+	if asset == "BTC" {
+		return 8
+	}
+	return 2
+}
+
+// BuildConvertResponse formats a conversion for the API response.
+func BuildConvertResponse(c *Conversion) map[string]any {
+	out := map[string]any{
+		"from":       c.From,
+		"to":         c.To,
+		"amount":     c.Amount.FloatString(decimalPlaces(c.Amount)),
+		"result":     c.Result,
+		"pair":       c.Pair,
+		"rate":       c.Rate,
+		"fetched_at": c.FetchedAt.UTC().Format(time.RFC3339Nano),
+		"precision":  c.Precision,
+		"rounding":   string(c.Rounding),
+	}
+	if !c.LastTradeAt.IsZero() {
+		out["last_trade_at"] = c.LastTradeAt.UTC().Format(time.RFC3339Nano)
+	}
+	return out
+}
+
+// decimalPlaces returns the number of decimal places needed to print r exactly.
+// r must have a terminating decimal expansion (as parsed by decimal.Parse).
+func decimalPlaces(r *big.Rat) int {
+	n := 0
+	x := new(big.Rat).Set(r)
+	ten := big.NewRat(10, 1)
+	for !x.IsInt() && n < 64 {
+		x.Mul(x, ten)
+		n++
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/decimal"
+)
+
+func TestParseConvertQuery(t *testing.T) {
+	req, err := ParseConvertQuery(url.Values{"from": {"chf"}, "to": {"btc"}, "amount": {"100"}, "rounding": {"down"}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if req.Pair != "BTC/CHF" || !req.Inverse || req.Precision != -1 || req.Rounding != decimal.Down {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	bad := []url.Values{
+		{"from": {"USD"}, "to": {"EUR"}, "amount": {"1"}},
+		{"from": {"BTC"}, "to": {"USD"}, "amount": {"-1"}},
+		{"from": {"BTC"}, "to": {"USD"}, "amount": {"1e3"}},
+		{"from": {"BTC"}, "to": {"USD"}, "amount": {"1"}, "precision": {"99"}},
+		{"from": {"BTC"}, "to": {"USD"}, "amount": {"1"}, "rounding": {"ceil"}},
+		{"to": {"USD"}, "amount": {"1"}},
+	}
+	for _, q := range bad {
+		if _, err := ParseConvertQuery(q); err == nil {
+			t.Fatalf("expected error for %v", q)
+		}
+	}
+}
+
+func TestService_Convert_Exact(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZCHF": 49000.12}}
+	s := New(mk, time.Minute)
+	ctx := context.Background()
+
+	req, _ := ParseConvertQuery(url.Values{"from": {"BTC"}, "to": {"CHF"}, "amount": {"0.35"}})
+	c, err := s.Convert(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// 0.35 * 49000.12 = 17150.042 exactly
+	if c.Result != "17150.04" || c.Precision != 2 || c.Rate != 49000.12 || c.FetchedAt.IsZero() {
+		t.Fatalf("unexpected conversion: %+v", c)
+	}
+
+	req, _ = ParseConvertQuery(url.Values{"from": {"CHF"}, "to": {"BTC"}, "amount": {"17150.042"}})
+	c, err = s.Convert(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if c.Result != "0.35000000" {
+		t.Fatalf("expected exact inverse 0.35000000, got %s", c.Result)
+	}
+	if got := BuildConvertResponse(c)["amount"]; got != "17150.042" {
+		t.Fatalf("expected amount echoed exactly, got %v", got)
+	}
+}
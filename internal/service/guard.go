@@ -0,0 +1,88 @@
+package service
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"bitcoin-prices/internal/metrics"
+	"bitcoin-prices/internal/pairs"
+)
+
+// GuardConfig configures the sanity checks applied to prices from Kraken
+// before they are cached.
+type GuardConfig struct {
+	// MaxDeviation is the largest accepted relative move against the last
+	// accepted price, e.g. 0.2 for 20%. Zero disables the deviation check.
+	MaxDeviation float64
+	// PerPair overrides MaxDeviation by external pair.
+	PerPair map[string]float64
+	// Window is how long the last accepted price serves as the reference.
+	// A move beyond the bound is accepted once the reference is older than
+	// this, so a genuine jump is served at most Window late.
+	Window time.Duration
+}
+
+// WithGuard configures the price sanity guard (default 20% within 1 minute).
+func WithGuard(cfg GuardConfig) Option {
+	return func(s *Service) {
+		if cfg.Window <= 0 {
+			cfg.Window = time.Minute
+		}
+		s.guard = cfg
+	}
+}
+
+// WithLogger sets the logger for service-level events such as rejected prices.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Service) { s.log = l }
+}
+
+// WithMetrics registers service metrics in reg.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(s *Service) { s.metrics = reg }
+}
+
+func (s *Service) maxDeviation(pair string) float64 {
+	if d, ok := s.guard.PerPair[pair]; ok {
+		return d
+	}
+	return s.guard.MaxDeviation
+}
+
+// acceptQuote validates a freshly fetched quote. Accepted quotes are cached
+// and passed to listeners. A rejected quote is logged and counted, and the
+// previously accepted quote (if any) is cached and returned in its place.
+// ok is false if there is nothing to serve.
+func (s *Service) acceptQuote(sym string, q Quote) (Quote, bool) {
+	pair, _ := pairs.ExternalPair(sym)
+
+	s.guardMu.Lock()
+	prev, hasPrev := s.accepted[sym]
+	recent := hasPrev && q.FetchedAt.Sub(prev.FetchedAt) <= s.guard.Window
+	var reason string
+	switch {
+	case math.IsNaN(q.Price) || math.IsInf(q.Price, 0):
+		reason = "not_finite"
+	case q.Price <= 0:
+		reason = "non_positive"
+	case recent && s.maxDeviation(pair) > 0 && math.Abs(q.Price-prev.Price)/prev.Price > s.maxDeviation(pair):
+		reason = "deviation"
+	}
+	if reason == "" {
+		s.accepted[sym] = q
+	}
+	s.guardMu.Unlock()
+
+	if reason == "" {
+		s.storeQuote(sym, q)
+		return q, true
+	}
+	s.rejections.Inc(pair, reason)
+	s.log.Warn("price rejected", "pair", pair, "price", q.Price, "reason", reason, "previous", prev.Price)
+	if !hasPrev {
+		return Quote{}, false
+	}
+	s.cache.Set(sym, prev)
+	return prev, true
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/metrics"
+
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+func TestService_Guard_RejectsBadPrices(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 50000, "XXBTZEUR": 45000}}
+	reg := metrics.NewRegistry()
+	s := New(mk, time.Nanosecond,
+		WithMetrics(reg),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithGuard(GuardConfig{MaxDeviation: 0.2, PerPair: map[string]float64{"BTC/EUR": 0.05}}),
+	)
+	ctx := context.Background()
+	get := func(pair string) (float64, bool) {
+		t.Helper()
+		res, err := s.GetLTP(ctx, []string{pair})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		v, ok := res[pair]
+		return v, ok
+	}
+
+	if v, _ := get("BTC/USD"); v != 50000 {
+		t.Fatalf("expected first price accepted, got %v", v)
+	}
+	mk.resp["XXBTZUSD"] = 75000 // +50%
+	if v, _ := get("BTC/USD"); v != 50000 {
+		t.Fatalf("expected jump rejected and previous kept, got %v", v)
+	}
+	mk.resp["XXBTZUSD"] = 0
+	if v, _ := get("BTC/USD"); v != 50000 {
+		t.Fatalf("expected zero rejected, got %v", v)
+	}
+	mk.resp["XXBTZUSD"] = 55000 // +10%, within default bound
+	if v, _ := get("BTC/USD"); v != 55000 {
+		t.Fatalf("expected move within bound accepted, got %v", v)
+	}
+
+	if v, _ := get("BTC/EUR"); v != 45000 {
+		t.Fatalf("unexpected EUR price %v", v)
+	}
+	mk.resp["XXBTZEUR"] = 45000 * 1.1 // beyond the 5% per-pair bound
+	if v, _ := get("BTC/EUR"); v != 45000 {
+		t.Fatalf("expected per-pair bound to reject, got %v", v)
+	}
+
+	mk.resp["XXBTZCHF"] = math.NaN()
+	if _, ok := get("BTC/CHF"); ok {
+		t.Fatalf("expected NaN without previous value to be omitted")
+	}
+
+	rej := reg.Counter("price_rejections_total", "")
+	if rej.Value("BTC/USD", "deviation") != 1 || rej.Value("BTC/USD", "non_positive") != 1 ||
+		rej.Value("BTC/EUR", "deviation") != 1 || rej.Value("BTC/CHF", "not_finite") != 1 {
+		t.Fatalf("unexpected rejection counts")
+	}
+}
+
+func TestService_Guard_AcceptsJumpAfterWindow(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 50000}}
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	s := New(mk, time.Second,
+		WithClock(clk),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithGuard(GuardConfig{MaxDeviation: 0.2, Window: time.Minute}),
+	)
+	ctx := context.Background()
+	if _, err := s.GetLTP(ctx, []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	mk.resp["XXBTZUSD"] = 80000
+	clk.Advance(time.Minute + time.Second)
+	res, err := s.GetLTP(ctx, []string{"BTC/USD"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res["BTC/USD"] != 80000 {
+		t.Fatalf("expected jump accepted once reference expired, got %v", res["BTC/USD"])
+	}
+}
@@ -4,11 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"bitcoin-prices/internal/cache"
+	"bitcoin-prices/internal/clock"
+	"bitcoin-prices/internal/decimal"
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/metrics"
 	"bitcoin-prices/internal/pairs"
 )
 
@@ -22,47 +34,287 @@ type KrakenTicker interface {
 
 type Service struct {
 	kraken KrakenTicker
-	cache  *cache.TTLCache[string, float64]
+	cache  cache.Cache[string, Quote]
+
+	ohlcMu      sync.Mutex
+	ohlc        map[ohlcKey]*ohlcSeries
+	ohlcCurrent *cache.TTLCache[ohlcKey, *kraken.Candle]
+
+	trades     *cache.TTLCache[string, *kraken.Trades]
+	lastTrades *cache.TTLCache[string, time.Time] // by Kraken symbol
+	cursorMu   sync.Mutex
+	cursors    map[string]tradeCursor
+	books      *cache.TTLCache[string, *OrderBook]
+	spreads    *cache.TTLCache[string, *Spread]
+	staleAfter time.Duration
+
+	rounding  decimal.RoundingMode
+	chunkSize int
+	listeners []QuoteListener
+
+	guard      GuardConfig
+	guardMu    sync.Mutex
+	accepted   map[string]Quote // last accepted quote per Kraken symbol
+	log        *slog.Logger
+	metrics    *metrics.Registry
+	rejections *metrics.Counter
+
+	snapMu            sync.Mutex
+	snapCalls         map[string]*snapshotCall // in-flight, by sorted pair set
+	snapshots         *cache.TTLCache[string, *Snapshot]
+	snapshotRetention time.Duration
+	snapshotTimeout   atomic.Int64 // time.Duration; see SetSnapshotTimeout
+
+	upstream  *cache.TTLCache[string, upstreamCheck]
+	maxSkew   time.Duration
+	lastFetch atomic.Int64 // unix nanos of the last successful price fetch
+
+	ttl             atomic.Int64 // time.Duration; see SetTTL
+	maxCacheEntries int
+	closers         []func()
+	clock           clock.Clock
+	tracer          trace.Tracer
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithClock sets the clock used for caching and fetch times (default clock.Real).
+func WithClock(clk clock.Clock) Option {
+	return func(s *Service) { s.clock = clk }
+}
+
+// WithTracerProvider traces price lookups with tp (default the global
+// provider).
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *Service) { s.tracer = tp.Tracer("bitcoin-prices/internal/service") }
+}
+
+// WithStaleAfter sets the age after which a pair's last trade is flagged as stale
+// (default 60s). Zero disables the flag.
+func WithStaleAfter(d time.Duration) Option {
+	return func(s *Service) { s.staleAfter = d }
+}
+
+func New(kr KrakenTicker, ttl time.Duration, opts ...Option) *Service {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	s := &Service{
+		kraken:     kr,
+		ohlc:       make(map[ohlcKey]*ohlcSeries),
+		staleAfter: time.Minute,
+		maxSkew:    5 * time.Second,
+		rounding:   decimal.HalfEven,
+		chunkSize:  defaultChunkSize,
+		guard:      GuardConfig{MaxDeviation: 0.2, Window: time.Minute},
+		accepted:   make(map[string]Quote),
+		log:        slog.Default(),
+		metrics:    metrics.NewRegistry(),
+
+		snapCalls:         make(map[string]*snapshotCall),
+		snapshotRetention: 5 * time.Minute,
+		maxCacheEntries:   defaultMaxCacheEntries,
+		clock:             clock.Real,
+		tracer:            otel.GetTracerProvider().Tracer("bitcoin-prices/internal/service"),
+	}
+	s.ttl.Store(int64(ttl))
+	s.snapshotTimeout.Store(int64(defaultSnapshotTimeout))
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.cache == nil {
+		s.cache = newCache[string, Quote](s, ttl)
+	}
+	s.ohlcCurrent = newCache[ohlcKey, *kraken.Candle](s, ttl)
+	s.trades = newCache[string, *kraken.Trades](s, ttl)
+	s.lastTrades = newCache[string, time.Time](s, ttl)
+	s.cursors = make(map[string]tradeCursor)
+	s.books = newCache[string, *OrderBook](s, ttl)
+	s.spreads = newCache[string, *Spread](s, ttl)
+	s.upstream = newCache[string, upstreamCheck](s, ttl)
+	s.snapshots = newCache[string, *Snapshot](s, s.snapshotRetention)
+	s.rejections = s.metrics.Counter("price_rejections_total", "Prices from Kraken rejected by the sanity guard.", "pair", "reason")
+	return s
+}
+
+const defaultMaxCacheEntries = 10000
+
+// WithMaxCacheEntries bounds each internal cache to n entries, evicting the
+// least recently used (default 10000; n <= 0 means unbounded).
+func WithMaxCacheEntries(n int) Option {
+	return func(s *Service) { s.maxCacheEntries = n }
+}
+
+// newCache returns a bounded cache whose expired entries are swept in the
+// background until Close.
+func newCache[K comparable, V any](s *Service, ttl time.Duration) *cache.TTLCache[K, V] {
+	interval := ttl
+	if interval < time.Second {
+		interval = time.Second
+	}
+	c := cache.New(ttl,
+		cache.WithMaxEntries[K, V](s.maxCacheEntries),
+		cache.WithJanitor[K, V](interval),
+		cache.WithClock[K, V](s.clock))
+	s.closers = append(s.closers, c.Close)
+	return c
+}
+
+// TTL returns the current price cache TTL.
+func (s *Service) TTL() time.Duration { return time.Duration(s.ttl.Load()) }
+
+// SetTTL changes the TTL of the price, trades, order book, spread, OHLC and
+// readiness caches at runtime. Entries already cached keep their expiry.
+func (s *Service) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.ttl.Store(int64(ttl))
+	if c, ok := s.cache.(interface{ SetDefaultTTL(time.Duration) }); ok {
+		c.SetDefaultTTL(ttl)
+	}
+	s.ohlcCurrent.SetDefaultTTL(ttl)
+	s.trades.SetDefaultTTL(ttl)
+	s.lastTrades.SetDefaultTTL(ttl)
+	s.books.SetDefaultTTL(ttl)
+	s.spreads.SetDefaultTTL(ttl)
+	s.upstream.SetDefaultTTL(ttl)
+}
+
+// Close stops background cache maintenance and closes the quote cache if it
+// holds resources (e.g. cache.RedisCache connections).
+func (s *Service) Close() {
+	for _, fn := range s.closers {
+		fn()
+	}
+	if c, ok := s.cache.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// Quote is a cached price with the time it was fetched from Kraken.
+type Quote struct {
+	Price     float64
+	FetchedAt time.Time
 }
 
-func New(kr KrakenTicker, ttl time.Duration) *Service {
-	return &Service{kraken: kr, cache: cache.New[string, float64](ttl)}
+// QuoteListener is notified of every price freshly fetched from Kraken.
+// It is called synchronously and must not block.
+type QuoteListener func(extPair string, q Quote)
+
+// WithQuoteListener registers a listener for fresh prices (e.g. alert evaluation).
+func WithQuoteListener(fn QuoteListener) Option {
+	return func(s *Service) { s.listeners = append(s.listeners, fn) }
+}
+
+// storeQuote caches an accepted quote and notifies listeners.
+func (s *Service) storeQuote(sym string, q Quote) {
+	s.cache.Set(sym, q)
+	if len(s.listeners) == 0 {
+		return
+	}
+	if p, ok := pairs.ExternalPair(sym); ok {
+		for _, fn := range s.listeners {
+			fn(p, q)
+		}
+	}
 }
 
 // GetLTP returns a map of external pair -> price.
 // It fetches missing pairs in batch from Kraken and populates the cache.
 func (s *Service) GetLTP(ctx context.Context, extPairs []string) (map[string]float64, error) {
+	ctx, span := s.tracer.Start(ctx, "Service.GetLTP")
+	defer span.End()
+	quotes, err := s.getQuotes(ctx, extPairs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(quotes))
+	for p, q := range quotes {
+		out[p] = q.Price
+	}
+	return out, nil
+}
+
+// GetQuotes is like GetLTP but also reports when each price was fetched.
+func (s *Service) GetQuotes(ctx context.Context, extPairs []string) (map[string]Quote, error) {
+	ctx, span := s.tracer.Start(ctx, "Service.GetQuotes")
+	defer span.End()
+	return s.getQuotes(ctx, extPairs)
+}
+
+// getQuotes serves GetLTP and GetQuotes, annotating the caller's span with
+// the cache hits and misses and any error.
+func (s *Service) getQuotes(ctx context.Context, extPairs []string) (map[string]Quote, error) {
+	span := trace.SpanFromContext(ctx)
 	if len(extPairs) == 0 {
 		return nil, errors.New("no pairs provided")
 	}
 	krSyms := pairs.KrakenSymbols(extPairs)
 	missing := make([]string, 0, len(krSyms))
-	krPrice := make(map[string]float64, len(krSyms))
+	krQuote := make(map[string]Quote, len(krSyms))
+	unknown := 0
 	for _, sym := range krSyms {
-		if v, ok := s.cache.Get(sym); ok {
-			krPrice[sym] = v
-		} else {
+		// misses are fetched below in one call for all symbols
+		switch q, found, negative := s.cache.Lookup(sym); {
+		case found:
+			krQuote[sym] = q
+		case negative:
+			unknown++ // left out, as when Kraken first reported it
+		default:
 			missing = append(missing, sym)
 		}
 	}
+	span.SetAttributes(attribute.Int("pairs", len(krSyms)), attribute.Int("cache.hits", len(krQuote)),
+		attribute.Int("cache.misses", len(missing)), attribute.Int("cache.negative_hits", unknown))
 	if len(missing) > 0 {
 		fresh, err := s.kraken.GetLastTradeClosed(ctx, missing)
+		if errors.Is(err, kraken.ErrUnknownPair) && len(missing) == 1 {
+			// with several symbols Kraken does not say which one it rejects
+			fresh, err = nil, nil
+		}
 		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
 			return nil, fmt.Errorf("kraken: %w", err)
 		}
-		for k, v := range fresh {
-			krPrice[k] = v
-			s.cache.Set(k, v)
+		now := s.clock.Now()
+		s.lastFetch.Store(now.UnixNano())
+		for _, sym := range missing {
+			v, ok := fresh[sym]
+			if !ok {
+				s.cache.GetOrSet(sym, func() (Quote, time.Duration, error) {
+					return Quote{}, unknownPairTTL, krakenErr(kraken.ErrUnknownPair)
+				})
+				continue
+			}
+			if q, ok := s.acceptQuote(sym, Quote{Price: v, FetchedAt: now}); ok {
+				krQuote[sym] = q
+			}
 		}
 	}
 	// Map back to external pairs
-	ext := pairs.MapKrakenToExternal(extPairs, krPrice)
-	return ext, nil
+	out := make(map[string]Quote, len(extPairs))
+	for _, p := range extPairs {
+		if sym, ok := pairs.KrakenSymbol(p); ok {
+			if q, ok := krQuote[sym]; ok {
+				out[p] = q
+			}
+		}
+	}
+	return out, nil
 }
 
 // BuildResponse formats the service response payload as required.
 // Sorted by pair for deterministic output.
-func BuildResponse(extPrices map[string]float64) map[string]any {
+// lastTrades is optional; pairs with a known last trade time get "last_trade_at"
+// and "stale" (last trade older than StaleAfter as of the service clock, if
+// StaleAfter > 0).
+func (s *Service) BuildResponse(extPrices map[string]float64, lastTrades map[string]time.Time) map[string]any {
+	return buildResponse(extPrices, lastTrades, s.staleAfter, s.clock.Now())
+}
+
+func buildResponse(extPrices map[string]float64, lastTrades map[string]time.Time, staleAfter time.Duration, now time.Time) map[string]any {
 	keys := make([]string, 0, len(extPrices))
 	for k := range extPrices {
 		keys = append(keys, k)
@@ -70,11 +322,25 @@ func BuildResponse(extPrices map[string]float64) map[string]any {
 	sort.Strings(keys)
 	ltp := make([]map[string]any, 0, len(keys))
 	for _, k := range keys {
-		ltp = append(ltp, map[string]any{"pair": k, "amount": extPrices[k]})
+		item := map[string]any{"pair": k, "amount": extPrices[k]}
+		if t, ok := lastTrades[k]; ok {
+			item["last_trade_at"] = t.UTC().Format(time.RFC3339Nano)
+			item["stale"] = staleAfter > 0 && now.Sub(t) > staleAfter
+		}
+		ltp = append(ltp, item)
 	}
 	return map[string]any{"ltp": ltp}
 }
 
+// WithQuoteCache replaces the in-memory price cache, e.g. with a cache.RedisCache
+// shared between replicas. Its TTL takes the place of the ttl passed to New.
+func WithQuoteCache(c cache.Cache[string, Quote]) Option {
+	return func(s *Service) { s.cache = c }
+}
+
+// StaleAfter returns the configured last-trade staleness threshold.
+func (s *Service) StaleAfter() time.Duration { return s.staleAfter }
+
 // ParsePairsQuery validates the pairs query string using pairs.NormalizePairs.
 func ParsePairsQuery(q string) ([]string, error) {
 	ps, err := pairs.NormalizePairs(q)
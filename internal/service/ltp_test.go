@@ -2,18 +2,25 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
+
+	"bitcoin-prices/internal/cache"
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/kraken"
 )
 
 type mockKraken struct {
 	calls int
+	asked []string // pairs of the last call
 	resp  map[string]float64
 	err   error
 }
 
 func (m *mockKraken) GetLastTradeClosed(ctx context.Context, krakenPairs []string) (map[string]float64, error) {
 	m.calls++
+	m.asked = krakenPairs
 	// return only requested keys that we have
 	out := make(map[string]float64)
 	for _, k := range krakenPairs {
@@ -55,3 +62,91 @@ func TestService_GetLTP_CachesResults(t *testing.T) {
 		t.Fatalf("expected cache hit (no new kraken call), got %d", mk.calls)
 	}
 }
+
+func TestService_GetLTP_NegativeCachesUnknownPair(t *testing.T) {
+	// Kraken leaves out BTC/EUR
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}}
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	s := New(mk, time.Second, WithClock(clk))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		clk.Advance(2 * time.Second) // the BTC/USD price expired
+		res, err := s.GetLTP(ctx, []string{"BTC/USD", "BTC/EUR"})
+		if err != nil || len(res) != 1 || res["BTC/USD"] != 52000 {
+			t.Fatalf("expected only BTC/USD, got %v err=%v", res, err)
+		}
+	}
+	if mk.calls != 2 || len(mk.asked) != 1 || mk.asked[0] != "XXBTZUSD" {
+		t.Fatalf("expected the unknown pair requested once, got %d calls, last for %v", mk.calls, mk.asked)
+	}
+
+	// Kraken rejects a single unknown pair with an error
+	mk.err = fmt.Errorf("%w: EQuery:Unknown asset pair", kraken.ErrUnknownPair)
+	for i := 0; i < 2; i++ {
+		if res, err := s.GetLTP(ctx, []string{"BTC/CHF"}); err != nil || len(res) != 0 {
+			t.Fatalf("expected an empty result, got %v err=%v", res, err)
+		}
+	}
+	if mk.calls != 3 {
+		t.Fatalf("expected the rejected pair requested once, got %d calls", mk.calls)
+	}
+
+	clk.Advance(unknownPairTTL + time.Second)
+	mk.err = nil
+	s.GetLTP(ctx, []string{"BTC/EUR"})
+	if mk.calls != 4 {
+		t.Fatalf("expected a retry after the negative TTL, got %d calls", mk.calls)
+	}
+}
+
+func TestService_QuoteListener(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000.12}}
+	got := map[string]float64{}
+	s := New(mk, time.Minute, WithQuoteListener(func(p string, q Quote) { got[p] = q.Price }))
+	ctx := context.Background()
+	if _, err := s.GetLTP(ctx, []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got["BTC/USD"] != 52000.12 {
+		t.Fatalf("expected listener to see fresh price, got %v", got)
+	}
+	delete(got, "BTC/USD")
+	if _, err := s.GetLTP(ctx, []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no notification for cached price, got %v", got)
+	}
+}
+
+func TestService_WithQuoteCache(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000.12}}
+	shared := cache.New[string, Quote](time.Minute)
+	a := New(mk, time.Minute, WithQuoteCache(shared))
+	b := New(mk, time.Minute, WithQuoteCache(shared))
+	if _, err := a.GetLTP(context.Background(), []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	res, err := b.GetLTP(context.Background(), []string{"BTC/USD"})
+	if err != nil || res["BTC/USD"] != 52000.12 {
+		t.Fatalf("unexpected result %v err=%v", res, err)
+	}
+	if mk.calls != 1 {
+		t.Fatalf("expected the second service to use the shared cache, got %d calls", mk.calls)
+	}
+}
+
+func TestService_CachesAreBounded(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000.12}}
+	s := New(mk, time.Minute, WithMaxCacheEntries(2))
+	defer s.Close()
+	for i := 0; i < 5; i++ {
+		if _, err := s.TakeSnapshot(context.Background(), []string{"BTC/USD"}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	if n := s.snapshots.Len(); n != 2 {
+		t.Fatalf("expected 2 retained snapshots, got %d", n)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"bitcoin-prices/internal/cache"
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// maxOHLCCandles bounds the closed candles kept per pair and interval.
+// Kraken itself only serves the most recent 720 data points.
+const maxOHLCCandles = 720
+
+// ErrUnsupported is returned when the upstream client lacks a capability.
+var ErrUnsupported = errors.New("operation not supported by upstream client")
+
+// unknownPairTTL is how long a pair Kraken reports as unknown is not
+// requested again.
+const unknownPairTTL = 30 * time.Second
+
+// krakenErr wraps an error from a per-pair Kraken call made in a cache
+// supplier. Unknown pairs also wrap cache.ErrNotFound to be negatively cached.
+func krakenErr(err error) error {
+	if errors.Is(err, kraken.ErrUnknownPair) {
+		return fmt.Errorf("kraken: %w (%w)", err, cache.ErrNotFound)
+	}
+	return fmt.Errorf("kraken: %w", err)
+}
+
+// unknownPair turns a negative cache hit into a descriptive error.
+func unknownPair(err error, sym string) error {
+	if errors.Is(err, cache.ErrNotFound) && !errors.Is(err, kraken.ErrUnknownPair) {
+		return fmt.Errorf("kraken: %w: %s (cached)", kraken.ErrUnknownPair, sym)
+	}
+	return err
+}
+
+// KrakenOHLC is the optional upstream capability needed for OHLC data.
+type KrakenOHLC interface {
+	GetOHLC(ctx context.Context, krakenPair string, interval int, since int64) (*kraken.OHLC, error)
+}
+
+type ohlcKey struct {
+	sym      string
+	interval int
+}
+
+// ohlcSeries holds the closed candles for a pair and interval. Closed candles
+// never change, so a series is only replaced (never mutated) when new ones arrive.
+type ohlcSeries struct {
+	closed []kraken.Candle
+	last   int64
+}
+
+// GetOHLC returns candles for an external pair and interval (minutes), with
+// open time at or after since (unix seconds).
+// Closed candles are cached indefinitely and fetched incrementally; the current
+// candle is cached for the service TTL.
+func (s *Service) GetOHLC(ctx context.Context, extPair string, interval int, since int64) ([]kraken.Candle, error) {
+	sym, ok := pairs.KrakenSymbol(extPair)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pair: %s", extPair)
+	}
+	if !kraken.ValidInterval(interval) {
+		return nil, fmt.Errorf("unsupported interval: %d", interval)
+	}
+	oc, ok := s.kraken.(KrakenOHLC)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	key := ohlcKey{sym: sym, interval: interval}
+
+	// a fresh current candle means the closed ones are up to date too
+	cur, err := s.ohlcCurrent.GetOrSet(key, func() (*kraken.Candle, time.Duration, error) {
+		var from int64
+		s.ohlcMu.Lock()
+		if series := s.ohlc[key]; series != nil {
+			from = series.last
+		}
+		s.ohlcMu.Unlock()
+		res, err := oc.GetOHLC(ctx, sym, interval, from)
+		if err != nil {
+			return nil, unknownPairTTL, krakenErr(err)
+		}
+		cur, ttl := s.mergeOHLC(key, res)
+		return cur, ttl, nil
+	})
+	if err != nil {
+		return nil, unknownPair(err, sym)
+	}
+	s.ohlcMu.Lock()
+	series := s.ohlc[key]
+	s.ohlcMu.Unlock()
+	if series == nil {
+		series = &ohlcSeries{}
+	}
+
+	out := make([]kraken.Candle, 0, len(series.closed)+1)
+	for _, c := range series.closed {
+		if c.Time >= since {
+			out = append(out, c)
+		}
+	}
+	if cur != nil && cur.Time >= since {
+		out = append(out, *cur)
+	}
+	return out, nil
+}
+
+// mergeOHLC folds freshly fetched candles into the cached series. It returns
+// the current candle (nil if none) and how long it may be cached.
+func (s *Service) mergeOHLC(key ohlcKey, res *kraken.OHLC) (*kraken.Candle, time.Duration) {
+	cutoff := s.clock.Now().Unix() - int64(key.interval*60)
+
+	s.ohlcMu.Lock()
+	defer s.ohlcMu.Unlock()
+
+	var closed []kraken.Candle
+	var last int64
+	if old := s.ohlc[key]; old != nil {
+		closed = append(closed, old.closed...)
+		last = old.last
+	}
+	var cur *kraken.Candle
+	for i := range res.Candles {
+		c := res.Candles[i]
+		if c.Time > cutoff {
+			// not closed yet
+			cur = &c
+			continue
+		}
+		switch n := len(closed); {
+		case n > 0 && c.Time == closed[n-1].Time:
+			closed[n-1] = c
+		case n == 0 || c.Time > closed[n-1].Time:
+			closed = append(closed, c)
+		}
+	}
+	if len(closed) > maxOHLCCandles {
+		closed = closed[len(closed)-maxOHLCCandles:]
+	}
+	if res.Last > last {
+		last = res.Last
+	}
+	s.ohlc[key] = &ohlcSeries{closed: closed, last: last}
+	// the current candle is final once its interval ends; the TTL is kept
+	// positive, as a non-positive one would mean the cache default
+	ttl := time.Duration(0)
+	if cur != nil {
+		ttl = max(min(s.TTL(), time.Unix(cur.Time+int64(key.interval*60), 0).Sub(s.clock.Now())), time.Nanosecond)
+	}
+	return cur, ttl
+}
+
+// BuildOHLCResponse formats OHLC candles for the API response.
+func BuildOHLCResponse(extPair string, interval int, candles []kraken.Candle) map[string]any {
+	rows := make([]map[string]any, 0, len(candles))
+	for _, c := range candles {
+		rows = append(rows, map[string]any{
+			"time":   c.Time,
+			"open":   c.Open,
+			"high":   c.High,
+			"low":    c.Low,
+			"close":  c.Close,
+			"vwap":   c.VWAP,
+			"volume": c.Volume,
+			"count":  c.Count,
+		})
+	}
+	return map[string]any{"pair": extPair, "interval": interval, "ohlc": rows}
+}
+
+// ParsePairQuery validates a single pair query value using pairs.NormalizePair.
+func ParsePairQuery(q string) (string, error) {
+	return pairs.NormalizePair(q)
+}
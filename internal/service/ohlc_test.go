@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/kraken"
+)
+
+type mockOHLC struct {
+	mockKraken
+	ohlcCalls int
+	sinces    []int64
+	res       *kraken.OHLC
+}
+
+func (m *mockOHLC) GetOHLC(ctx context.Context, krakenPair string, interval int, since int64) (*kraken.OHLC, error) {
+	m.ohlcCalls++
+	m.sinces = append(m.sinces, since)
+	return m.res, m.err
+}
+
+func TestService_GetOHLC_CachesClosedAndCurrent(t *testing.T) {
+	now := time.Now().Unix() / 60 * 60
+	mk := &mockOHLC{res: &kraken.OHLC{
+		Candles: []kraken.Candle{
+			{Time: now - 120, Close: 1},
+			{Time: now - 60, Close: 2},
+			{Time: now, Close: 3},
+		},
+		Last: now - 60,
+	}}
+	clk := clocktest.NewFake(time.Unix(now, 0))
+	s := New(mk, 10*time.Second, WithClock(clk))
+	ctx := context.Background()
+
+	got, err := s.GetOHLC(ctx, "BTC/USD", 1, 0)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(got) != 3 || got[2].Close != 3 {
+		t.Fatalf("expected 3 candles ending with current, got %+v", got)
+	}
+	if _, err := s.GetOHLC(ctx, "BTC/USD", 1, now-60); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.ohlcCalls != 1 {
+		t.Fatalf("expected cache hit, got %d calls", mk.ohlcCalls)
+	}
+
+	// current candle expires: refetch incrementally from last committed id
+	clk.Advance(11 * time.Second)
+	mk.res = &kraken.OHLC{Candles: []kraken.Candle{{Time: now, Close: 4}}, Last: now - 60}
+	got, err = s.GetOHLC(ctx, "BTC/USD", 1, now-60)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.ohlcCalls != 2 || mk.sinces[1] != now-60 {
+		t.Fatalf("expected incremental refetch since %d, got calls=%d sinces=%v", now-60, mk.ohlcCalls, mk.sinces)
+	}
+	if len(got) != 2 || got[0].Close != 2 || got[1].Close != 4 {
+		t.Fatalf("unexpected candles after refresh: %+v", got)
+	}
+}
+
+func TestService_GetOHLC_Unsupported(t *testing.T) {
+	s := New(&mockKraken{}, time.Second)
+	if _, err := s.GetOHLC(context.Background(), "BTC/USD", 1, 0); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestService_GetOHLC_NegativeCachesUnknownPair(t *testing.T) {
+	mk := &mockOHLC{}
+	mk.err = fmt.Errorf("%w: EQuery:Unknown asset pair", kraken.ErrUnknownPair)
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	s := New(mk, time.Second, WithClock(clk))
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetOHLC(context.Background(), "BTC/USD", 1, 0); !errors.Is(err, kraken.ErrUnknownPair) {
+			t.Fatalf("expected ErrUnknownPair, got %v", err)
+		}
+	}
+	if mk.ohlcCalls != 1 {
+		t.Fatalf("expected unknown pair to be requested once, got %d", mk.ohlcCalls)
+	}
+	clk.Advance(unknownPairTTL + time.Second)
+	s.GetOHLC(context.Background(), "BTC/USD", 1, 0)
+	if mk.ohlcCalls != 2 {
+		t.Fatalf("expected a retry after the negative TTL, got %d calls", mk.ohlcCalls)
+	}
+}
+
+func TestService_GetOHLC_CurrentCandleExpiresAtClose(t *testing.T) {
+	start := int64(1700000000) / 60 * 60
+	mk := &mockOHLC{res: &kraken.OHLC{Candles: []kraken.Candle{{Time: start, Close: 1}}, Last: start - 60}}
+	clk := clocktest.NewFake(time.Unix(start+30, 0))
+	s := New(mk, 10*time.Minute, WithClock(clk))
+	ctx := context.Background()
+
+	if _, err := s.GetOHLC(ctx, "BTC/USD", 1, 0); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	clk.Advance(29 * time.Second)
+	s.GetOHLC(ctx, "BTC/USD", 1, 0)
+	if mk.ohlcCalls != 1 {
+		t.Fatalf("expected cache hit within the candle, got %d calls", mk.ohlcCalls)
+	}
+	clk.Advance(2 * time.Second) // the candle has closed
+	s.GetOHLC(ctx, "BTC/USD", 1, 0)
+	if mk.ohlcCalls != 2 {
+		t.Fatalf("expected refetch once the candle closed, got %d calls", mk.ohlcCalls)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// KrakenSystem is the optional upstream capability needed for readiness checks.
+type KrakenSystem interface {
+	GetSystemStatus(ctx context.Context) (*kraken.SystemStatus, error)
+	GetServerTime(ctx context.Context) (time.Time, error)
+}
+
+// Readiness describes whether the service can currently serve fresh prices.
+type Readiness struct {
+	Ready          bool
+	UpstreamStatus string // Kraken system status, or "unknown" if it could not be fetched
+	UpstreamError  string
+	ClockSkew      time.Duration // Kraken time minus local time; zero if unknown
+	SkewKnown      bool
+	LastFetch      time.Time // last successful Kraken price fetch; zero if none yet
+	CacheWarm      int       // supported pairs with a fresh cached price
+	CacheTotal     int
+}
+
+// upstreamCheck is a cached result of the Kraken status and time calls.
+type upstreamCheck struct {
+	status    string
+	err       error
+	skew      time.Duration
+	skewKnown bool
+}
+
+// WithMaxClockSkew sets the largest tolerated difference between local and
+// Kraken server time before the service reports not ready (default 5s).
+func WithMaxClockSkew(d time.Duration) Option {
+	return func(s *Service) { s.maxSkew = d }
+}
+
+// Readiness checks Kraken's system status and clock skew (cached for the
+// service TTL) and reports cache warmth and the last successful fetch.
+// The service is ready when Kraken is reachable, not in maintenance and the
+// clock skew is within bounds, or when all enabled pairs are cached.
+func (s *Service) Readiness(ctx context.Context) Readiness {
+	enabled := pairs.Enabled()
+	r := Readiness{CacheTotal: len(enabled)}
+	for _, sym := range pairs.KrakenSymbols(enabled) {
+		if _, ok := s.cache.Get(sym); ok {
+			r.CacheWarm++
+		}
+	}
+	if ns := s.lastFetch.Load(); ns != 0 {
+		r.LastFetch = time.Unix(0, ns)
+	}
+
+	chk, _ := s.upstream.GetOrSet("system", func() (upstreamCheck, time.Duration, error) {
+		chk := s.checkUpstream(ctx)
+		if chk.err != nil {
+			// re-check soon so readiness recovers quickly
+			return chk, min(s.TTL(), failedCheckTTL), nil
+		}
+		return chk, 0, nil
+	})
+	r.UpstreamStatus = chk.status
+	if chk.err != nil {
+		r.UpstreamError = chk.err.Error()
+	}
+	r.ClockSkew, r.SkewKnown = chk.skew, chk.skewKnown
+
+	skewOK := !chk.skewKnown || s.maxSkew <= 0 || (chk.skew <= s.maxSkew && chk.skew >= -s.maxSkew)
+	usable := chk.err == nil && chk.status != kraken.StatusMaintenance
+	r.Ready = (usable && skewOK) || r.CacheWarm == r.CacheTotal
+	return r
+}
+
+// failedCheckTTL caps how long a failed upstream check is cached.
+const failedCheckTTL = 2 * time.Second
+
+func (s *Service) checkUpstream(ctx context.Context) upstreamCheck {
+	sc, ok := s.kraken.(KrakenSystem)
+	if !ok {
+		return upstreamCheck{status: "unknown", err: ErrUnsupported}
+	}
+	chk := upstreamCheck{status: "unknown"}
+	st, err := sc.GetSystemStatus(ctx)
+	if err != nil {
+		chk.err = err
+		return chk
+	}
+	chk.status = st.Status
+
+	before := s.clock.Now()
+	srvTime, err := sc.GetServerTime(ctx)
+	if err != nil {
+		return chk
+	}
+	// compare against the midpoint of the round trip
+	local := before.Add(s.clock.Now().Sub(before) / 2)
+	chk.skew = srvTime.Sub(local).Truncate(time.Millisecond)
+	chk.skewKnown = true
+	return chk
+}
+
+// BuildReadyResponse formats readiness for the API response.
+func BuildReadyResponse(r Readiness) map[string]any {
+	out := map[string]any{
+		"ready": r.Ready,
+		"upstream": map[string]any{
+			"status": r.UpstreamStatus,
+		},
+		"cache": map[string]any{
+			"warm":  r.CacheWarm,
+			"total": r.CacheTotal,
+		},
+	}
+	up := out["upstream"].(map[string]any)
+	if r.UpstreamError != "" {
+		up["error"] = r.UpstreamError
+	}
+	if r.SkewKnown {
+		out["clock_skew_ms"] = r.ClockSkew.Milliseconds()
+	}
+	if !r.LastFetch.IsZero() {
+		out["last_fetch_at"] = r.LastFetch.UTC().Format(time.RFC3339Nano)
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+)
+
+type mockSystem struct {
+	mockKraken
+	status    string
+	statusErr error
+	skew      time.Duration
+}
+
+func (m *mockSystem) GetSystemStatus(ctx context.Context) (*kraken.SystemStatus, error) {
+	if m.statusErr != nil {
+		return nil, m.statusErr
+	}
+	return &kraken.SystemStatus{Status: m.status}, nil
+}
+
+func (m *mockSystem) GetServerTime(ctx context.Context) (time.Time, error) {
+	return time.Now().Add(m.skew), nil
+}
+
+func TestService_Readiness(t *testing.T) {
+	prices := map[string]float64{"XXBTZUSD": 1, "XXBTZEUR": 2, "XXBTZCHF": 3}
+	cases := []struct {
+		name      string
+		mk        *mockSystem
+		warm      bool
+		wantReady bool
+	}{
+		{"online", &mockSystem{status: kraken.StatusOnline}, false, true},
+		{"maintenance", &mockSystem{status: kraken.StatusMaintenance}, false, false},
+		{"maintenance with warm cache", &mockSystem{status: kraken.StatusMaintenance}, true, true},
+		{"unreachable", &mockSystem{statusErr: errors.New("down")}, false, false},
+		{"clock skew", &mockSystem{status: kraken.StatusOnline, skew: time.Minute}, false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mk.resp = prices
+			s := New(tc.mk, time.Minute)
+			ctx := context.Background()
+			if tc.warm {
+				if _, err := s.GetLTP(ctx, []string{"BTC/USD", "BTC/EUR", "BTC/CHF"}); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+			}
+			r := s.Readiness(ctx)
+			if r.Ready != tc.wantReady {
+				t.Fatalf("expected ready=%v, got %+v", tc.wantReady, r)
+			}
+			if tc.warm && (r.CacheWarm != 3 || r.LastFetch.IsZero()) {
+				t.Fatalf("expected warm cache and last fetch, got %+v", r)
+			}
+		})
+	}
+}
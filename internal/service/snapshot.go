@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bitcoin-prices/internal/pairs"
+)
+
+// ErrSnapshotNotFound is returned for unknown or expired snapshot IDs.
+var ErrSnapshotNotFound = errors.New("snapshot not found or expired")
+
+// defaultSnapshotTimeout matches the default handler timeout, which exceeds
+// the Kraken retry budget of the default client.
+const defaultSnapshotTimeout = 8 * time.Second
+
+// Snapshot is a set of prices taken from a single Kraken Ticker call.
+type Snapshot struct {
+	ID      string
+	TakenAt time.Time
+	Quotes  map[string]Quote // by external pair
+}
+
+// Prices returns the snapshot prices by external pair.
+func (s *Snapshot) Prices() map[string]float64 {
+	out := make(map[string]float64, len(s.Quotes))
+	for p, q := range s.Quotes {
+		out[p] = q.Price
+	}
+	return out
+}
+
+type snapshotCall struct {
+	done chan struct{}
+	snap *Snapshot
+	err  error
+}
+
+// WithSnapshotRetention sets how long snapshots can be retrieved by ID
+// (default 5 minutes).
+func WithSnapshotRetention(d time.Duration) Option {
+	return func(s *Service) { s.snapshotRetention = d }
+}
+
+// WithSnapshotTimeout bounds the Kraken call behind a snapshot, which is
+// shared by the callers asking for it and not tied to any one's context
+// (default 8s). It should exceed the Kraken client's retry budget (see
+// kraken.RetryBudget) so a snapshot gets Kraken's answer or its last error.
+func WithSnapshotTimeout(d time.Duration) Option {
+	return func(s *Service) { s.SetSnapshotTimeout(d) }
+}
+
+// SetSnapshotTimeout changes the snapshot timeout at runtime, for example
+// after the Kraken retries changed; see WithSnapshotTimeout.
+func (s *Service) SetSnapshotTimeout(d time.Duration) {
+	if d > 0 {
+		s.snapshotTimeout.Store(int64(d))
+	}
+}
+
+// TakeSnapshot fetches all extPairs in one Kraken call, bypassing the cache, so
+// every price in the result was traded as of the same moment. Concurrent
+// callers asking for the same pair set share one call and one snapshot.
+// Pairs whose price is rejected by the sanity guard are left out rather than
+// filled in from an older fetch.
+func (s *Service) TakeSnapshot(ctx context.Context, extPairs []string) (*Snapshot, error) {
+	if len(extPairs) == 0 {
+		return nil, errors.New("no pairs provided")
+	}
+	set := append([]string(nil), extPairs...)
+	sort.Strings(set)
+	key := strings.Join(set, ",")
+
+	s.snapMu.Lock()
+	c, ok := s.snapCalls[key]
+	if !ok {
+		c = &snapshotCall{done: make(chan struct{})}
+		s.snapCalls[key] = c
+		timeout := time.Duration(s.snapshotTimeout.Load())
+		go func() {
+			fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+			defer cancel()
+			c.snap, c.err = s.takeSnapshot(fctx, set)
+			s.snapMu.Lock()
+			delete(s.snapCalls, key)
+			s.snapMu.Unlock()
+			close(c.done)
+		}()
+	}
+	s.snapMu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.snap, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Service) takeSnapshot(ctx context.Context, extPairs []string) (*Snapshot, error) {
+	fresh, err := s.kraken.GetLastTradeClosed(ctx, pairs.KrakenSymbols(extPairs))
+	if err != nil {
+		return nil, fmt.Errorf("kraken: %w", err)
+	}
+	now := s.clock.Now()
+	s.lastFetch.Store(now.UnixNano())
+	snap := &Snapshot{ID: newSnapshotID(), TakenAt: now, Quotes: make(map[string]Quote, len(extPairs))}
+	for _, p := range extPairs {
+		sym, _ := pairs.KrakenSymbol(p)
+		v, ok := fresh[sym]
+		if !ok {
+			continue
+		}
+		if q, ok := s.acceptQuote(sym, Quote{Price: v, FetchedAt: now}); ok && q.FetchedAt.Equal(now) {
+			snap.Quotes[p] = q
+		}
+	}
+	s.snapshots.Set(snap.ID, snap)
+	return snap, nil
+}
+
+// GetSnapshot returns a snapshot taken within the retention window.
+func (s *Service) GetSnapshot(id string) (*Snapshot, error) {
+	snap, ok := s.snapshots.Get(id)
+	if !ok {
+		return nil, ErrSnapshotNotFound
+	}
+	return snap, nil
+}
+
+// BuildSnapshotResponse formats a snapshot like BuildResponse, plus its ID and
+// time. Last trade times are omitted as they are not part of the snapshot.
+func BuildSnapshotResponse(snap *Snapshot) map[string]any {
+	resp := buildResponse(snap.Prices(), nil, 0, snap.TakenAt)
+	resp["snapshot_id"] = snap.ID
+	resp["snapshot_at"] = snap.TakenAt.UTC().Format(time.RFC3339Nano)
+	return resp
+}
+
+func newSnapshotID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
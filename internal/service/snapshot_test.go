@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+)
+
+// gateKraken blocks every call until release is closed.
+type gateKraken struct {
+	calls   atomic.Int32
+	release chan struct{}
+	resp    map[string]float64
+}
+
+func (g *gateKraken) GetLastTradeClosed(ctx context.Context, krakenPairs []string) (map[string]float64, error) {
+	g.calls.Add(1)
+	<-g.release
+	out := make(map[string]float64)
+	for _, k := range krakenPairs {
+		if v, ok := g.resp[k]; ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// joinCtx signals joined when TakeSnapshot starts waiting on it, which a
+// caller only does once it has started or joined the Kraken call.
+type joinCtx struct {
+	context.Context
+	joined chan<- struct{}
+}
+
+func (c joinCtx) Done() <-chan struct{} {
+	c.joined <- struct{}{}
+	return c.Context.Done()
+}
+
+func TestService_TakeSnapshot_CoalescesAndRetains(t *testing.T) {
+	gk := &gateKraken{release: make(chan struct{}), resp: map[string]float64{"XXBTZUSD": 52000, "XXBTZEUR": 50000}}
+	s := New(gk, time.Minute)
+
+	const callers = 5
+	snaps := make([]*Snapshot, callers)
+	joined := make(chan struct{}, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snap, err := s.TakeSnapshot(joinCtx{context.Background(), joined}, []string{"BTC/USD", "BTC/EUR"})
+			if err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			snaps[i] = snap
+		}(i)
+	}
+	// Kraken does not answer until every caller waits for the snapshot
+	for i := 0; i < callers; i++ {
+		<-joined
+	}
+	close(gk.release)
+	wg.Wait()
+
+	if n := gk.calls.Load(); n != 1 {
+		t.Fatalf("expected 1 kraken call, got %d", n)
+	}
+	for _, snap := range snaps {
+		if snap == nil || snap.ID != snaps[0].ID {
+			t.Fatalf("expected all callers to share one snapshot")
+		}
+	}
+	if len(snaps[0].Quotes) != 2 || snaps[0].Quotes["BTC/USD"].FetchedAt != snaps[0].TakenAt {
+		t.Fatalf("unexpected snapshot: %+v", snaps[0])
+	}
+
+	// a later snapshot is a new Kraken call, even with a warm cache
+	next, err := s.TakeSnapshot(context.Background(), []string{"BTC/EUR", "BTC/USD"})
+	if err != nil || next.ID == snaps[0].ID || gk.calls.Load() != 2 {
+		t.Fatalf("expected a new snapshot, got %+v err=%v", next, err)
+	}
+
+	got, err := s.GetSnapshot(snaps[0].ID)
+	if err != nil || got.Prices()["BTC/USD"] != 52000 {
+		t.Fatalf("expected retained snapshot, got %+v err=%v", got, err)
+	}
+	if _, err := s.GetSnapshot("unknown"); err != ErrSnapshotNotFound {
+		t.Fatalf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
+func TestService_GetSnapshot_Expires(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000}}
+	clk := clocktest.NewFake(time.Unix(0, 0))
+	s := New(mk, time.Minute, WithClock(clk), WithSnapshotRetention(time.Minute))
+	snap, err := s.TakeSnapshot(context.Background(), []string{"BTC/USD"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	clk.Advance(time.Minute + time.Nanosecond)
+	if _, err := s.GetSnapshot(snap.ID); err != ErrSnapshotNotFound {
+		t.Fatalf("expected expired snapshot, got %v", err)
+	}
+}
+
+// hangKraken blocks every call until its context is done.
+type hangKraken struct{}
+
+func (hangKraken) GetLastTradeClosed(ctx context.Context, krakenPairs []string) (map[string]float64, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestService_TakeSnapshot_Timeout(t *testing.T) {
+	s := New(hangKraken{}, time.Minute, WithSnapshotTimeout(time.Millisecond))
+	// the caller waits longer than the shared call may take
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := s.TakeSnapshot(ctx, []string{"BTC/USD"}); !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		t.Fatalf("expected the snapshot call to time out, got %v", err)
+	}
+}
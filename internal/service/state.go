@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// State file layout: magic, version (uint32), payload length (uint64),
+// CRC-32 (IEEE) of the payload (uint32), then the JSON payload.
+const (
+	stateMagic   = "BTCPSTAT"
+	stateVersion = 1
+	maxStateSize = 64 << 20
+)
+
+var (
+	// ErrStateCorrupt is returned when a state file fails its integrity checks.
+	ErrStateCorrupt = errors.New("state file corrupt")
+	// ErrStateVersion is returned for state files written by an incompatible version.
+	ErrStateVersion = errors.New("unsupported state file version")
+)
+
+// StateInfo summarizes what LoadState restored.
+type StateInfo struct {
+	SavedAt time.Time
+	Fresh   int // prices still within the cache TTL, served from the cache
+	Stale   int // older prices, kept only as sanity guard references
+	Series  int // OHLC series of closed candles
+}
+
+type stateFile struct {
+	SavedAt time.Time              `json:"saved_at"`
+	Quotes  map[string]storedQuote `json:"quotes"` // by Kraken symbol
+	OHLC    []storedSeries         `json:"ohlc"`
+}
+
+type storedQuote struct {
+	Price     float64   `json:"price"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+type storedSeries struct {
+	Pair     string         `json:"pair"` // Kraken symbol
+	Interval int            `json:"interval"`
+	Last     int64          `json:"last"`
+	Candles  []storedCandle `json:"candles"`
+}
+
+type storedCandle struct {
+	Time   int64   `json:"t"`
+	Open   float64 `json:"o"`
+	High   float64 `json:"h"`
+	Low    float64 `json:"l"`
+	Close  float64 `json:"c"`
+	VWAP   float64 `json:"vwap"`
+	Volume float64 `json:"v"`
+	Count  int     `json:"n"`
+}
+
+// SaveState writes the last accepted prices and the closed OHLC candles to
+// path, atomically (temp file + rename).
+func (s *Service) SaveState(path string) error {
+	st := stateFile{SavedAt: s.clock.Now().UTC(), Quotes: make(map[string]storedQuote)}
+
+	s.guardMu.Lock()
+	for sym, q := range s.accepted {
+		st.Quotes[sym] = storedQuote{Price: q.Price, FetchedAt: q.FetchedAt}
+	}
+	s.guardMu.Unlock()
+
+	s.ohlcMu.Lock()
+	for key, series := range s.ohlc {
+		ss := storedSeries{Pair: key.sym, Interval: key.interval, Last: series.last}
+		for _, c := range series.closed {
+			ss.Candles = append(ss.Candles, storedCandle{
+				Time: c.Time, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close,
+				VWAP: c.VWAP, Volume: c.Volume, Count: c.Count,
+			})
+		}
+		st.OHLC = append(st.OHLC, ss)
+	}
+	s.ohlcMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := encodeState(&buf, st); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	// flush to disk before the rename, so a crash cannot leave an empty file
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState restores state written by SaveState. A missing file restores
+// nothing. Prices are cached only for what is left of the TTL measured from
+// when they were fetched; older ones are not served as fresh.
+func (s *Service) LoadState(path string) (StateInfo, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return StateInfo{}, nil
+	}
+	if err != nil {
+		return StateInfo{}, err
+	}
+	defer f.Close()
+	st, err := decodeState(f)
+	if err != nil {
+		return StateInfo{}, fmt.Errorf("load %s: %w", path, err)
+	}
+
+	info := StateInfo{SavedAt: st.SavedAt}
+	now, ttl := s.clock.Now(), s.TTL()
+	s.guardMu.Lock()
+	for sym, sq := range st.Quotes {
+		q := Quote{Price: sq.Price, FetchedAt: sq.FetchedAt}
+		if prev, ok := s.accepted[sym]; ok && !q.FetchedAt.After(prev.FetchedAt) {
+			continue // already have a newer price
+		}
+		s.accepted[sym] = q
+		if left := ttl - now.Sub(q.FetchedAt); left > 0 {
+			s.cache.SetWithTTL(sym, q, min(left, ttl))
+			info.Fresh++
+		} else {
+			info.Stale++
+		}
+	}
+	s.guardMu.Unlock()
+
+	s.ohlcMu.Lock()
+	for _, ss := range st.OHLC {
+		key := ohlcKey{sym: ss.Pair, interval: ss.Interval}
+		if _, ok := s.ohlc[key]; ok || !kraken.ValidInterval(key.interval) {
+			continue
+		}
+		if _, ok := pairs.ExternalPair(key.sym); !ok {
+			continue
+		}
+		series := &ohlcSeries{last: ss.Last, closed: make([]kraken.Candle, 0, len(ss.Candles))}
+		for _, c := range ss.Candles {
+			series.closed = append(series.closed, kraken.Candle{
+				Time: c.Time, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close,
+				VWAP: c.VWAP, Volume: c.Volume, Count: c.Count,
+			})
+		}
+		s.ohlc[key] = series
+		info.Series++
+	}
+	s.ohlcMu.Unlock()
+	return info, nil
+}
+
+func encodeState(w io.Writer, st stateFile) error {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	hdr := make([]byte, 0, len(stateMagic)+16)
+	hdr = append(hdr, stateMagic...)
+	hdr = binary.BigEndian.AppendUint32(hdr, stateVersion)
+	hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(payload)))
+	hdr = binary.BigEndian.AppendUint32(hdr, crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err = w.Write(payload)
+	return err
+}
+
+func decodeState(r io.Reader) (stateFile, error) {
+	var st stateFile
+	hdr := make([]byte, len(stateMagic)+16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return st, fmt.Errorf("%w: short header", ErrStateCorrupt)
+	}
+	if string(hdr[:len(stateMagic)]) != stateMagic {
+		return st, fmt.Errorf("%w: bad magic", ErrStateCorrupt)
+	}
+	rest := hdr[len(stateMagic):]
+	if v := binary.BigEndian.Uint32(rest); v != stateVersion {
+		return st, fmt.Errorf("%w: %d", ErrStateVersion, v)
+	}
+	size := binary.BigEndian.Uint64(rest[4:])
+	sum := binary.BigEndian.Uint32(rest[12:])
+	if size > maxStateSize {
+		return st, fmt.Errorf("%w: payload too large", ErrStateCorrupt)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return st, fmt.Errorf("%w: truncated payload", ErrStateCorrupt)
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return st, fmt.Errorf("%w: checksum mismatch", ErrStateCorrupt)
+	}
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return st, fmt.Errorf("%w: %v", ErrStateCorrupt, err)
+	}
+	return st, nil
+}
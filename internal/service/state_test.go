@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/kraken"
+)
+
+func TestService_SaveLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	start := time.Unix(1700000000/60*60, 0)
+	clk := clocktest.NewFake(start)
+
+	mk := &mockOHLC{res: &kraken.OHLC{Candles: []kraken.Candle{{Time: start.Unix() - 120, Close: 1, Count: 3}}, Last: start.Unix() - 120}}
+	mk.resp = map[string]float64{"XXBTZUSD": 52000.12}
+	s := New(mk, 10*time.Second, WithClock(clk))
+	if _, err := s.GetLTP(context.Background(), []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	clk.Advance(5 * time.Second)
+	mk.resp = map[string]float64{"XXBTZEUR": 50000.12}
+	if _, err := s.GetLTP(context.Background(), []string{"BTC/EUR"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := s.GetOHLC(context.Background(), "BTC/USD", 1, 0); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := s.SaveState(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// restart 7s later: BTC/USD (12s old) is stale, BTC/EUR (7s old) has 3s left
+	clk.Advance(7 * time.Second)
+	mk2 := &mockOHLC{}
+	mk2.resp = map[string]float64{"XXBTZUSD": 52100, "XXBTZEUR": 50100}
+	s2 := New(mk2, 10*time.Second, WithClock(clk))
+	info, err := s2.LoadState(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if info.Fresh != 1 || info.Stale != 1 || info.Series != 1 {
+		t.Fatalf("unexpected load info: %+v", info)
+	}
+	res, err := s2.GetLTP(context.Background(), []string{"BTC/USD", "BTC/EUR"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res["BTC/EUR"] != 50000.12 || res["BTC/USD"] != 52100 || mk2.calls != 1 {
+		t.Fatalf("expected restored EUR and refetched USD, got %v (%d calls)", res, mk2.calls)
+	}
+	clk.Advance(4 * time.Second)
+	if res, _ := s2.GetLTP(context.Background(), []string{"BTC/EUR"}); res["BTC/EUR"] != 50100 {
+		t.Fatalf("expected restored price to expire on its original schedule, got %v", res)
+	}
+
+	s2.ohlcMu.Lock()
+	series := s2.ohlc[ohlcKey{sym: "XXBTZUSD", interval: 1}]
+	s2.ohlcMu.Unlock()
+	if series == nil || len(series.closed) != 1 || series.closed[0].Count != 3 || series.last != start.Unix()-120 {
+		t.Fatalf("unexpected restored series: %+v", series)
+	}
+}
+
+func TestService_LoadState_Errors(t *testing.T) {
+	dir := t.TempDir()
+	s := New(&mockKraken{}, time.Second)
+	if info, err := s.LoadState(filepath.Join(dir, "missing")); err != nil || info.Fresh != 0 {
+		t.Fatalf("expected missing file to load nothing, got %+v %v", info, err)
+	}
+
+	path := filepath.Join(dir, "state.bin")
+	if err := s.SaveState(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	good, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		mutate func([]byte) []byte
+		want   error
+	}{
+		"flipped payload byte": {func(b []byte) []byte { b[len(b)-2] ^= 0xff; return b }, ErrStateCorrupt},
+		"truncated":            {func(b []byte) []byte { return b[:len(b)-1] }, ErrStateCorrupt},
+		"bad magic":            {func(b []byte) []byte { b[0] = 'x'; return b }, ErrStateCorrupt},
+		"future version":       {func(b []byte) []byte { b[len(stateMagic)+3] = 9; return b }, ErrStateVersion},
+	} {
+		b := tc.mutate(append([]byte(nil), good...))
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.LoadState(path); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
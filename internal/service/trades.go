@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bitcoin-prices/internal/kraken"
+	"bitcoin-prices/internal/pairs"
+)
+
+// KrakenTrades is the optional upstream capability needed for public trades.
+type KrakenTrades interface {
+	GetTrades(ctx context.Context, krakenPair string, since int64) (*kraken.Trades, error)
+}
+
+// GetTrades returns recent trades for an external pair that happened after since.
+// The most recent trades per pair are cached for the service TTL.
+func (s *Service) GetTrades(ctx context.Context, extPair string, since time.Time) ([]kraken.Trade, error) {
+	sym, ok := pairs.KrakenSymbol(extPair)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pair: %s", extPair)
+	}
+	res, err := s.recentTrades(ctx, sym)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]kraken.Trade, 0, len(res.Trades))
+	for _, t := range res.Trades {
+		if t.Time.After(since) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// LastTradeTimes returns the time of the most recent trade for each external pair.
+// It is best-effort: pairs whose trades cannot be fetched are omitted. Times
+// are cached for the service TTL; refreshing one asks Kraken only for the
+// trades since the previous poll.
+func (s *Service) LastTradeTimes(ctx context.Context, extPairs []string) map[string]time.Time {
+	out := make(map[string]time.Time, len(extPairs))
+	tc, ok := s.kraken.(KrakenTrades)
+	if !ok {
+		return out
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range extPairs {
+		sym, ok := pairs.KrakenSymbol(p)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(p, sym string) {
+			defer wg.Done()
+			t, err := s.lastTradeTime(ctx, tc, sym)
+			if err != nil || t.IsZero() {
+				return
+			}
+			mu.Lock()
+			out[p] = t
+			mu.Unlock()
+		}(p, sym)
+	}
+	wg.Wait()
+	return out
+}
+
+// tradeCursor is how far the trades of a Kraken symbol have been polled.
+type tradeCursor struct {
+	at   time.Time // most recent trade seen
+	last int64     // Kraken's "since" for the next poll
+}
+
+func (s *Service) lastTradeTime(ctx context.Context, tc KrakenTrades, sym string) (time.Time, error) {
+	t, err := s.lastTrades.GetOrSet(sym, func() (time.Time, time.Duration, error) {
+		s.cursorMu.Lock()
+		cur := s.cursors[sym]
+		s.cursorMu.Unlock()
+		res, err := tc.GetTrades(ctx, sym, cur.last)
+		if err != nil {
+			return time.Time{}, unknownPairTTL, krakenErr(err)
+		}
+		s.cursorMu.Lock()
+		defer s.cursorMu.Unlock()
+		// merge with the stored cursor, which a concurrent poll may have moved
+		cur = s.cursors[sym]
+		if n := len(res.Trades); n > 0 && res.Trades[n-1].Time.After(cur.at) {
+			cur.at = res.Trades[n-1].Time
+		}
+		cur.last = max(cur.last, res.Last)
+		s.cursors[sym] = cur
+		return cur.at, 0, nil
+	})
+	return t, unknownPair(err, sym)
+}
+
+func (s *Service) recentTrades(ctx context.Context, sym string) (*kraken.Trades, error) {
+	tc, ok := s.kraken.(KrakenTrades)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	res, err := s.trades.GetOrSet(sym, func() (*kraken.Trades, time.Duration, error) {
+		res, err := tc.GetTrades(ctx, sym, 0)
+		if err != nil {
+			return nil, unknownPairTTL, krakenErr(err)
+		}
+		return res, 0, nil
+	})
+	return res, unknownPair(err, sym)
+}
+
+// BuildTradesResponse formats trades for the API response.
+func BuildTradesResponse(extPair string, trades []kraken.Trade) map[string]any {
+	rows := make([]map[string]any, 0, len(trades))
+	for _, t := range trades {
+		rows = append(rows, map[string]any{
+			"id":     t.ID,
+			"price":  t.Price,
+			"volume": t.Volume,
+			"time":   t.Time.UTC().Format(time.RFC3339Nano),
+			"side":   t.Side,
+			"type":   t.OrderType,
+		})
+	}
+	return map[string]any{"pair": extPair, "trades": rows}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/kraken"
+)
+
+type mockTrades struct {
+	mockKraken
+	tradeCalls int
+	trades     map[string][]kraken.Trade
+	last       int64   // returned as the cursor
+	since      []int64 // as asked for
+}
+
+func (m *mockTrades) GetTrades(ctx context.Context, krakenPair string, since int64) (*kraken.Trades, error) {
+	m.tradeCalls++
+	m.since = append(m.since, since)
+	return &kraken.Trades{Trades: m.trades[krakenPair], Last: m.last}, m.err
+}
+
+func TestService_LastTradeTimes(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	t1 := clk.Now().Add(-10 * time.Second)
+	t2 := clk.Now().Add(-90 * time.Second)
+	mk := &mockTrades{trades: map[string][]kraken.Trade{
+		"XXBTZUSD": {{Time: t1.Add(-time.Second)}, {Time: t1}},
+		"XXBTZEUR": {{Time: t2}},
+	}}
+	s := New(mk, time.Minute, WithClock(clk))
+
+	got := s.LastTradeTimes(context.Background(), []string{"BTC/USD", "BTC/EUR", "BTC/CHF"})
+	if !got["BTC/USD"].Equal(t1) || !got["BTC/EUR"].Equal(t2) {
+		t.Fatalf("unexpected last trade times: %v", got)
+	}
+	if _, ok := got["BTC/CHF"]; ok {
+		t.Fatalf("expected BTC/CHF without trades to be omitted")
+	}
+
+	resp := s.BuildResponse(map[string]float64{"BTC/USD": 1, "BTC/EUR": 2, "BTC/CHF": 3}, got)
+	items := resp["ltp"].([]map[string]any)
+	stale := map[string]any{}
+	for _, it := range items {
+		stale[it["pair"].(string)] = it["stale"]
+	}
+	if stale["BTC/USD"] != false || stale["BTC/EUR"] != true || stale["BTC/CHF"] != nil {
+		t.Fatalf("unexpected stale flags: %v", stale)
+	}
+
+	// staleness follows the service clock, not the wall clock
+	clk.Advance(time.Minute)
+	for _, it := range s.BuildResponse(map[string]float64{"BTC/USD": 1}, got)["ltp"].([]map[string]any) {
+		if it["stale"] != true {
+			t.Fatalf("expected BTC/USD to go stale on the service clock, got %v", it)
+		}
+	}
+}
+
+func TestService_LastTradeTimes_PollsIncrementally(t *testing.T) {
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	t1 := clk.Now().Add(-time.Second)
+	mk := &mockTrades{trades: map[string][]kraken.Trade{"XXBTZUSD": {{Time: t1}}}, last: 100}
+	s := New(mk, time.Minute, WithClock(clk))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if got := s.LastTradeTimes(ctx, []string{"BTC/USD"}); !got["BTC/USD"].Equal(t1) {
+			t.Fatalf("unexpected last trade times: %v", got)
+		}
+	}
+	if mk.tradeCalls != 1 {
+		t.Fatalf("expected the last trade time cached, got %d calls", mk.tradeCalls)
+	}
+
+	// no trades since the cursor: the time is kept
+	clk.Advance(2 * time.Minute)
+	mk.trades, mk.last = nil, 100
+	if got := s.LastTradeTimes(ctx, []string{"BTC/USD"}); !got["BTC/USD"].Equal(t1) {
+		t.Fatalf("expected the earlier trade kept, got %v", got)
+	}
+	t2 := clk.Now()
+	clk.Advance(2 * time.Minute)
+	mk.trades, mk.last = map[string][]kraken.Trade{"XXBTZUSD": {{Time: t2}}}, 200
+	if got := s.LastTradeTimes(ctx, []string{"BTC/USD"}); !got["BTC/USD"].Equal(t2) {
+		t.Fatalf("expected the new trade, got %v", got)
+	}
+	if fmt.Sprint(mk.since) != "[0 100 100]" {
+		t.Fatalf("expected polls since the previous cursor, got %v", mk.since)
+	}
+}
+
+func TestService_GetTrades_FiltersAndCaches(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	mk := &mockTrades{trades: map[string][]kraken.Trade{
+		"XXBTZUSD": {{Time: base, ID: 1}, {Time: base.Add(time.Second), ID: 2}},
+	}}
+	s := New(mk, time.Minute)
+	ctx := context.Background()
+
+	got, err := s.GetTrades(ctx, "BTC/USD", base)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("expected only trade 2, got %+v", got)
+	}
+	if _, err := s.GetTrades(ctx, "BTC/USD", time.Time{}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.tradeCalls != 1 {
+		t.Fatalf("expected cached trades, got %d calls", mk.tradeCalls)
+	}
+}
+
+func TestService_GetTrades_NegativeCachesUnknownPair(t *testing.T) {
+	mk := &mockTrades{}
+	mk.err = fmt.Errorf("%w: EQuery:Unknown asset pair", kraken.ErrUnknownPair)
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	s := New(mk, time.Second, WithClock(clk))
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetTrades(context.Background(), "BTC/USD", time.Time{}); !errors.Is(err, kraken.ErrUnknownPair) {
+			t.Fatalf("expected ErrUnknownPair, got %v", err)
+		}
+	}
+	if mk.tradeCalls != 1 {
+		t.Fatalf("expected unknown pair to be requested once, got %d", mk.tradeCalls)
+	}
+	clk.Advance(unknownPairTTL + time.Second)
+	s.GetTrades(context.Background(), "BTC/USD", time.Time{})
+	if mk.tradeCalls != 2 {
+		t.Fatalf("expected a retry after the negative TTL, got %d calls", mk.tradeCalls)
+	}
+}
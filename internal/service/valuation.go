@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"bitcoin-prices/internal/decimal"
+	"bitcoin-prices/internal/pairs"
+)
+
+// MaxHoldings bounds the line items accepted in a single valuation request.
+const MaxHoldings = 100
+
+// Holding is a validated valuation line item.
+type Holding struct {
+	Asset   string
+	Quote   string
+	Amount  *big.Rat
+	Pair    string
+	Inverse bool
+}
+
+// ItemError is a validation error for a single line item.
+type ItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// ErrPriceRejected is reported for a pair whose freshly fetched price the
+// sanity guard rejected, so it cannot be valued with the others.
+var ErrPriceRejected = errors.New("price rejected by the sanity guard")
+
+// PriceErrors reports the pairs a valuation has no price for, by pair.
+type PriceErrors map[string]error
+
+func (e PriceErrors) Error() string {
+	ps := make([]string, 0, len(e))
+	for p := range e {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	msgs := make([]string, len(ps))
+	for i, p := range ps {
+		msgs[i] = p + ": " + e[p].Error()
+	}
+	return "no price for " + strings.Join(msgs, "; ")
+}
+
+// ValuedHolding is a holding with its value in the quote currency.
+type ValuedHolding struct {
+	Holding
+	Rate  float64
+	Value *big.Rat
+}
+
+// Valuation is the result of Value. All rates come from the same Kraken fetch.
+type Valuation struct {
+	Holdings  []ValuedHolding
+	Totals    map[string]*big.Rat // by quote currency
+	FetchedAt time.Time
+	Rounding  decimal.RoundingMode
+}
+
+type holdingJSON struct {
+	Asset  string      `json:"asset"`
+	Amount json.Number `json:"amount"`
+	Quote  string      `json:"quote"`
+}
+
+// ParseHoldings decodes {"holdings":[{"asset","amount","quote"}]} and validates
+// each line item. Amounts may be JSON numbers or decimal strings.
+// It returns an error for a malformed body and item errors for invalid line items.
+func ParseHoldings(r io.Reader) ([]Holding, []ItemError, error) {
+	var body struct {
+		Holdings []holdingJSON `json:"holdings"`
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		return nil, nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if len(body.Holdings) == 0 {
+		return nil, nil, errors.New("no holdings provided")
+	}
+	if len(body.Holdings) > MaxHoldings {
+		return nil, nil, fmt.Errorf("too many holdings: %d (max %d)", len(body.Holdings), MaxHoldings)
+	}
+	out := make([]Holding, 0, len(body.Holdings))
+	var errs []ItemError
+	for i, h := range body.Holdings {
+		hd, err := parseHolding(h)
+		if err != nil {
+			errs = append(errs, ItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		out = append(out, hd)
+	}
+	return out, errs, nil
+}
+
+func parseHolding(h holdingJSON) (Holding, error) {
+	asset := strings.ToUpper(strings.TrimSpace(h.Asset))
+	quote := strings.ToUpper(strings.TrimSpace(h.Quote))
+	if asset == "" || quote == "" {
+		return Holding{}, errors.New("asset and quote are required")
+	}
+	pair, inverse, ok := pairs.Direct(asset, quote)
+	if !ok {
+		return Holding{}, fmt.Errorf("no direct pair for %s/%s", asset, quote)
+	}
+	amount, err := decimal.Parse(h.Amount.String())
+	if err != nil {
+		return Holding{}, err
+	}
+	if amount.Sign() < 0 {
+		return Holding{}, errors.New("amount must not be negative")
+	}
+	return Holding{Asset: asset, Quote: quote, Amount: amount, Pair: pair, Inverse: inverse}, nil
+}
+
+// Value values holdings from a consistent price snapshot. If a pair has no
+// price from that fetch, e.g. because the guard rejected it, the error is a
+// PriceErrors.
+func (s *Service) Value(ctx context.Context, holdings []Holding) (*Valuation, error) {
+	set := make(map[string]struct{}, len(holdings))
+	var extPairs []string
+	for _, h := range holdings {
+		if _, ok := set[h.Pair]; !ok {
+			set[h.Pair] = struct{}{}
+			extPairs = append(extPairs, h.Pair)
+		}
+	}
+	quotes, at, err := s.consistentQuotes(ctx, extPairs)
+	if err != nil {
+		return nil, err
+	}
+	v := &Valuation{Totals: make(map[string]*big.Rat), FetchedAt: at, Rounding: s.rounding}
+	for _, h := range holdings {
+		q := quotes[h.Pair]
+		rate := decimal.FromFloat(q.Price)
+		val := new(big.Rat)
+		if h.Inverse {
+			val.Quo(h.Amount, rate)
+		} else {
+			val.Mul(h.Amount, rate)
+		}
+		v.Holdings = append(v.Holdings, ValuedHolding{Holding: h, Rate: q.Price, Value: val})
+		if v.Totals[h.Quote] == nil {
+			v.Totals[h.Quote] = new(big.Rat)
+		}
+		v.Totals[h.Quote].Add(v.Totals[h.Quote], val)
+	}
+	return v, nil
+}
+
+// consistentQuotes returns quotes for extPairs that all come from the Kraken
+// fetch at the returned time. Cached quotes are reused only if they already
+// share one.
+func (s *Service) consistentQuotes(ctx context.Context, extPairs []string) (map[string]Quote, time.Time, error) {
+	out := make(map[string]Quote, len(extPairs))
+	var at time.Time
+	for _, p := range extPairs {
+		sym, _ := pairs.KrakenSymbol(p)
+		q, ok := s.cache.Get(sym)
+		if !ok || (!at.IsZero() && !q.FetchedAt.Equal(at)) {
+			return s.fetchQuotes(ctx, extPairs)
+		}
+		at = q.FetchedAt
+		out[p] = q
+	}
+	return out, at, nil
+}
+
+// fetchQuotes fetches all extPairs in one Kraken call, bypassing (and
+// refreshing) the cache. Like takeSnapshot it only uses prices from this
+// fetch: a rejected price is reported rather than replaced by an older one.
+func (s *Service) fetchQuotes(ctx context.Context, extPairs []string) (map[string]Quote, time.Time, error) {
+	fresh, err := s.kraken.GetLastTradeClosed(ctx, pairs.KrakenSymbols(extPairs))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("kraken: %w", err)
+	}
+	now := s.clock.Now()
+	s.lastFetch.Store(now.UnixNano())
+	out := make(map[string]Quote, len(extPairs))
+	errs := make(PriceErrors)
+	for _, p := range extPairs {
+		sym, _ := pairs.KrakenSymbol(p)
+		v, ok := fresh[sym]
+		if !ok {
+			errs[p] = errors.New("kraken returned no price")
+			continue
+		}
+		if q, ok := s.acceptQuote(sym, Quote{Price: v, FetchedAt: now}); ok && q.FetchedAt.Equal(now) {
+			out[p] = q
+		} else {
+			errs[p] = ErrPriceRejected
+		}
+	}
+	if len(errs) > 0 {
+		return nil, time.Time{}, errs
+	}
+	return out, now, nil
+}
+
+// BuildValuationResponse formats a valuation for the API response. Values are
+// rounded to the quote currency's default precision; totals are summed exactly
+// before rounding.
+func BuildValuationResponse(v *Valuation) map[string]any {
+	items := make([]map[string]any, 0, len(v.Holdings))
+	for _, h := range v.Holdings {
+		items = append(items, map[string]any{
+			"asset":  h.Asset,
+			"amount": h.Amount.FloatString(decimalPlaces(h.Amount)),
+			"quote":  h.Quote,
+			"pair":   h.Pair,
+			"rate":   h.Rate,
+			"value":  decimal.Round(h.Value, DefaultPrecision(h.Quote), v.Rounding),
+		})
+	}
+	quotes := make([]string, 0, len(v.Totals))
+	for q := range v.Totals {
+		quotes = append(quotes, q)
+	}
+	sort.Strings(quotes)
+	totals := make(map[string]string, len(quotes))
+	for _, q := range quotes {
+		totals[q] = decimal.Round(v.Totals[q], DefaultPrecision(q), v.Rounding)
+	}
+	return map[string]any{
+		"holdings":   items,
+		"totals":     totals,
+		"fetched_at": v.FetchedAt.UTC().Format(time.RFC3339Nano),
+		"rounding":   string(v.Rounding),
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bitcoin-prices/internal/clock/clocktest"
+	"bitcoin-prices/internal/decimal"
+)
+
+func TestParseHoldings_ItemErrors(t *testing.T) {
+	body := `{"holdings":[
+		{"asset":"BTC","amount":"1.5","quote":"USD"},
+		{"asset":"USD","amount":1,"quote":"EUR"},
+		{"asset":"BTC","amount":"-1","quote":"CHF"},
+		{"asset":"btc","amount":0.25,"quote":"chf"}
+	]}`
+	hs, errs, err := ParseHoldings(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(hs) != 2 || len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 2 {
+		t.Fatalf("unexpected result: holdings=%+v errs=%+v", hs, errs)
+	}
+	if _, _, err := ParseHoldings(strings.NewReader(`{"holdings":[]}`)); err == nil {
+		t.Fatalf("expected error for empty holdings")
+	}
+	if _, _, err := ParseHoldings(strings.NewReader(`{"holdings":`)); err == nil {
+		t.Fatalf("expected error for malformed body")
+	}
+}
+
+func TestService_Value_ConsistentSnapshot(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000.12, "XXBTZCHF": 49000.12}}
+	s := New(mk, time.Minute)
+	ctx := context.Background()
+
+	// warm only one pair so cached quotes come from different fetches
+	if _, err := s.GetLTP(ctx, []string{"BTC/USD"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	hs, _, _ := ParseHoldings(strings.NewReader(`{"holdings":[
+		{"asset":"BTC","amount":"1.5","quote":"USD"},
+		{"asset":"BTC","amount":"0.5","quote":"USD"},
+		{"asset":"CHF","amount":"49000.12","quote":"BTC"}
+	]}`))
+	v, err := s.Value(ctx, hs)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.calls != 2 {
+		t.Fatalf("expected a fresh batch fetch for a consistent snapshot, got %d calls", mk.calls)
+	}
+	if got := decimal.Round(v.Totals["USD"], 2, decimal.HalfEven); got != "104000.24" {
+		t.Fatalf("unexpected USD total: %s", got)
+	}
+	if got := decimal.Round(v.Totals["BTC"], 8, decimal.HalfEven); got != "1.00000000" {
+		t.Fatalf("unexpected BTC total: %s", got)
+	}
+
+	// now all quotes share one fetch: served from cache
+	if _, err := s.Value(ctx, hs); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if mk.calls != 2 {
+		t.Fatalf("expected cached consistent snapshot, got %d calls", mk.calls)
+	}
+}
+
+func TestService_Value_RejectedPrice(t *testing.T) {
+	mk := &mockKraken{resp: map[string]float64{"XXBTZUSD": 52000, "XXBTZCHF": 49000}}
+	clk := clocktest.NewFake(time.Unix(1700000000, 0))
+	s := New(mk, time.Minute, WithClock(clk), WithGuard(GuardConfig{MaxDeviation: 0.2, Window: time.Hour}))
+	ctx := context.Background()
+	hs, _, _ := ParseHoldings(strings.NewReader(`{"holdings":[
+		{"asset":"BTC","amount":"1","quote":"USD"},
+		{"asset":"BTC","amount":"1","quote":"CHF"}
+	]}`))
+	v, err := s.Value(ctx, hs)
+	if err != nil || !v.FetchedAt.Equal(clk.Now()) {
+		t.Fatalf("unexpected valuation %+v err=%v", v, err)
+	}
+
+	// BTC/USD jumps 50%: the guard keeps the old quote, which is from an
+	// earlier fetch than the new BTC/CHF one
+	clk.Advance(2 * time.Minute)
+	mk.resp = map[string]float64{"XXBTZUSD": 78000, "XXBTZCHF": 49100}
+	_, err = s.Value(ctx, hs)
+	var perr PriceErrors
+	if !errors.As(err, &perr) || len(perr) != 1 || !errors.Is(perr["BTC/USD"], ErrPriceRejected) {
+		t.Fatalf("expected BTC/USD reported as rejected, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package tracing sets up OpenTelemetry tracing: the span exporter, sampling
+// and W3C trace-context propagation. Instrumented packages take a
+// trace.TracerProvider option and default to the global (no-op) provider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Propagator reads and writes W3C traceparent/tracestate and baggage headers.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{}, propagation.Baggage{})
+
+// Config selects where spans go.
+type Config struct {
+	// Exporter is "stdout" (JSON lines), "otlp" (OTLP over HTTP to
+	// Endpoint) or "none".
+	Exporter string
+	// Endpoint is the collector's base URL, e.g. http://collector:4318;
+	// /v1/traces is appended unless it has a path.
+	Endpoint string
+	// SampleRatio is the share of new traces recorded; requests with a
+	// sampled parent are always recorded.
+	SampleRatio float64
+	ServiceName string
+	Writer      io.Writer // stdout exporter output (default os.Stdout)
+}
+
+// New returns a TracerProvider exporting spans per cfg and a function that
+// flushes and stops it. With no exporter the provider is a no-op.
+func New(ctx context.Context, cfg Config) (trace.TracerProvider, func(context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "none":
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case "stdout":
+		w := cfg.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case "otlp":
+		endpoint, perr := traceURL(cfg.Endpoint)
+		if perr != nil {
+			return nil, nil, perr
+		}
+		exp, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	default:
+		return nil, nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("tracing: %s exporter: %w", cfg.Exporter, err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	return tp, tp.Shutdown, nil
+}
+
+func traceURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("tracing: endpoint must be an http(s) URL, got %q", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
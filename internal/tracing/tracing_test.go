@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestNew_Stdout(t *testing.T) {
+	var buf bytes.Buffer
+	tp, shutdown, err := New(context.Background(), Config{Exporter: "stdout", SampleRatio: 1, ServiceName: "test", Writer: &buf})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	_, span := tp.Tracer("test").Start(context.Background(), "work")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Name":"work"`) || !strings.Contains(buf.String(), `"Value":"test"`) {
+		t.Fatalf("expected the span with its service name, got %s", buf.String())
+	}
+}
+
+func TestNew_OTLP(t *testing.T) {
+	var posts atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			posts.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	tp, shutdown, err := New(context.Background(), Config{Exporter: "otlp", Endpoint: collector.URL, SampleRatio: 1, ServiceName: "test"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	_, span := tp.Tracer("test").Start(context.Background(), "work")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if posts.Load() == 0 {
+		t.Fatalf("expected spans posted to the collector")
+	}
+}
+
+func TestNew_SamplingAndNone(t *testing.T) {
+	tp, shutdown, err := New(context.Background(), Config{Exporter: "stdout", SampleRatio: 0, ServiceName: "test", Writer: &bytes.Buffer{}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer shutdown(context.Background())
+	if _, span := tp.Tracer("test").Start(context.Background(), "root"); span.SpanContext().IsSampled() {
+		t.Fatalf("expected new traces dropped at ratio 0")
+	}
+	// a sampled caller is always followed
+	carrier := propagation.HeaderCarrier{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ctx := Propagator.Extract(context.Background(), carrier)
+	if _, span := tp.Tracer("test").Start(ctx, "child"); !span.SpanContext().IsSampled() {
+		t.Fatalf("expected the sampled parent to be followed")
+	}
+
+	tp, _, err = New(context.Background(), Config{Exporter: "none"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, span := tp.Tracer("test").Start(context.Background(), "x"); span.IsRecording() || span.SpanContext().IsValid() {
+		t.Fatalf("expected a no-op span")
+	}
+	if _, _, err := New(context.Background(), Config{Exporter: "jaeger"}); err == nil {
+		t.Fatalf("expected error for an unknown exporter")
+	}
+	if _, _, err := New(context.Background(), Config{Exporter: "otlp", Endpoint: "collector:4318"}); err == nil {
+		t.Fatalf("expected error for an endpoint without scheme")
+	}
+}
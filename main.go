@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -9,16 +12,46 @@ import (
 	"syscall"
 	"time"
 
+	"bitcoin-prices/internal/config"
 	"bitcoin-prices/internal/httpapi"
+	"bitcoin-prices/internal/logging"
 )
 
 func main() {
-	addr := ":" + getenv("PORT", "8080")
-	srv := httpapi.NewServer(addr)
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	level := new(slog.LevelVar)
+	level.UnmarshalText([]byte(cfg.Log.Level))
+	// validated by config.Load
+	logger, _ := logging.New(os.Stdout, cfg.Log.Format, level)
+	slog.SetDefault(logger)
+
+	srv, err := httpapi.NewServer(cfg, logger, httpapi.WithLevelVar(level), httpapi.WithConfigLoader(func() (config.Config, error) {
+		return config.Load(os.Args[1:], os.Getenv)
+	}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot start: %v\n", err)
+		os.Exit(1)
+	}
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_, _ = srv.Reload() // outcome is logged
+		}
+	}()
+
 	go func() {
 		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
 			slog.Error("server error", "err", err)
@@ -31,10 +64,3 @@ func main() {
 	defer cancel()
 	_ = srv.Shutdown(ctx)
 }
-
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}